@@ -0,0 +1,161 @@
+package cipher
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ---------------------------
+// alphabets
+// ---------------------------
+
+// Alphabet is an ordered set of runes; a rune's position is its numeral
+// value and the number of runes is the radix of the FPE domain.
+type Alphabet struct {
+	runes []rune
+	index map[rune]int
+}
+
+// NewAlphabet builds an alphabet from the runes of chars, in order.
+// It needs at least two runes, all distinct and valid UTF-8.
+func NewAlphabet(chars string) (*Alphabet, error) {
+	if !utf8.ValidString(chars) {
+		return nil, errors.New("alphabet is not valid UTF-8")
+	}
+	return newAlphabet([]rune(chars))
+}
+
+// MustAlphabet is NewAlphabet that panics on error, for package-level vars.
+func MustAlphabet(chars string) *Alphabet {
+	a, err := NewAlphabet(chars)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+func newAlphabet(runes []rune) (*Alphabet, error) {
+	if len(runes) < 2 {
+		return nil, errors.New("alphabet needs at least 2 runes")
+	}
+	a := &Alphabet{runes: runes, index: make(map[rune]int, len(runes))}
+	for i, r := range runes {
+		if r == utf8.RuneError {
+			return nil, errors.New("alphabet contains U+FFFD")
+		}
+		if _, dup := a.index[r]; dup {
+			return nil, fmt.Errorf("alphabet repeats %q", r)
+		}
+		a.index[r] = i
+	}
+	return a, nil
+}
+
+// Radix returns the number of runes in a.
+func (a *Alphabet) Radix() int { return len(a.runes) }
+
+// Rune returns the rune with numeral value i.
+func (a *Alphabet) Rune(i int) rune { return a.runes[i] }
+
+// Index returns the numeral value of r, or -1 if r is not in a.
+func (a *Alphabet) Index(r rune) int {
+	if i, ok := a.index[r]; ok {
+		return i
+	}
+	return -1
+}
+
+// Contains reports whether r is in a.
+func (a *Alphabet) Contains(r rune) bool {
+	_, ok := a.index[r]
+	return ok
+}
+
+// String returns the runes of a in order.
+func (a *Alphabet) String() string { return string(a.runes) }
+
+// without returns a minus the runes for which drop is true, keeping
+// order, or nil when fewer than two runes are left.
+func (a *Alphabet) without(drop func(rune) bool) *Alphabet {
+	var kept []rune
+	for _, r := range a.runes {
+		if !drop(r) {
+			kept = append(kept, r)
+		}
+	}
+	if len(kept) == len(a.runes) {
+		return a
+	}
+	b, err := newAlphabet(kept)
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// Built-in alphabets.
+var (
+	AlphabetDigits = MustAlphabet("0123456789")
+	AlphabetUpper  = MustAlphabet("ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	AlphabetLower  = MustAlphabet("abcdefghijklmnopqrstuvwxyz")
+
+	// AlphabetHex is lowercase hexadecimal.
+	AlphabetHex = MustAlphabet("0123456789abcdef")
+
+	// AlphabetCrockford32 is Crockford's base32: digits and uppercase
+	// letters without the easily confused I, L, O and U.
+	AlphabetCrockford32 = MustAlphabet("0123456789ABCDEFGHJKMNPQRSTVWXYZ")
+
+	// AlphabetAtext is the RFC 5322 atext set of unquoted email local
+	// parts: letters, digits and !#$%&'*+-/=?^_`{|}~ (81 runes).
+	AlphabetAtext = MustAlphabet("0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz" +
+		"!#$%&'*+-/=?^_`{|}~")
+)
+
+// Built-in alphabets for non-ASCII scripts. Each one covers the ASCII
+// letters its script also uses, so registering it (see RegisterClass)
+// leaves no letters of a word in a separate class. Alphabets of the same
+// case overlap (e.g. Vietnamese and Latin-1 share 'a' and 'é'), so only
+// one of them can be registered per cipher. Input is expected in NFC:
+// a decomposed combining mark is not a letter and is copied as-is.
+var (
+	// AlphabetVietnameseLower is a-z, đ and every vowel a ă â e ê i o ô ơ
+	// u ư y with each of the five tone marks (93 runes).
+	AlphabetVietnameseLower = MustAlphabet(
+		"aàáảãạăằắẳẵặâầấẩẫậeèéẻẽẹêềếểễệiìíỉĩịoòóỏõọôồốổỗộơờớởỡợuùúủũụưừứửữựyỳýỷỹỵ" +
+			"bcdđfghjklmnpqrstvwxz")
+	// AlphabetVietnameseUpper is the uppercase of AlphabetVietnameseLower.
+	AlphabetVietnameseUpper = MustAlphabet(
+		"AÀÁẢÃẠĂẰẮẲẴẶÂẦẤẨẪẬEÈÉẺẼẸÊỀẾỂỄỆIÌÍỈĨỊOÒÓỎÕỌÔỒỐỔỖỘƠỜỚỞỠỢUÙÚỦŨỤƯỪỨỬỮỰYỲÝỶỸỴ" +
+			"BCDĐFGHJKLMNPQRSTVWXZ")
+
+	// AlphabetLatin1Lower is a-z plus the Latin-1 lowercase letters ß-ö, ø-ÿ.
+	AlphabetLatin1Lower = MustAlphabet("abcdefghijklmnopqrstuvwxyz" +
+		runeSpan('ß', 'ö') + runeSpan('ø', 'ÿ'))
+	// AlphabetLatin1Upper is A-Z plus the Latin-1 uppercase letters À-Ö, Ø-Þ.
+	AlphabetLatin1Upper = MustAlphabet("ABCDEFGHIJKLMNOPQRSTUVWXYZ" +
+		runeSpan('À', 'Ö') + runeSpan('Ø', 'Þ'))
+
+	// AlphabetCyrillicLower is а-я and ѐ-џ (Russian, Ukrainian, Belarusian,
+	// Serbian, Macedonian) plus ґ.
+	AlphabetCyrillicLower = MustAlphabet(runeSpan('а', 'џ') + "ґ")
+	// AlphabetCyrillicUpper is the uppercase of AlphabetCyrillicLower.
+	AlphabetCyrillicUpper = MustAlphabet(runeSpan('Ѐ', 'Я') + "Ґ")
+
+	// AlphabetGreekLower is α-ω (with final ς) and the monotonic accented
+	// and diaeresis vowels.
+	AlphabetGreekLower = MustAlphabet(runeSpan('α', 'ω') + "άέήίόύώϊϋΐΰ")
+	// AlphabetGreekUpper is Α-Ω and the monotonic accented and diaeresis vowels.
+	AlphabetGreekUpper = MustAlphabet(runeSpan('Α', 'Ρ') + runeSpan('Σ', 'Ω') + "ΆΈΉΊΌΎΏΪΫ")
+)
+
+// runeSpan returns the runes lo..hi inclusive as a string.
+func runeSpan(lo, hi rune) string {
+	var b strings.Builder
+	for r := lo; r <= hi; r++ {
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,415 @@
+package cipher
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ---------------------------
+// check-digit schemes
+// ---------------------------
+
+// CheckDigitScheme describes identifiers that end in check characters
+// computed from the rest (the payload), such as ISBNs or EAN barcodes.
+// EncryptCheckDigit encrypts the payload and recomputes the check.
+type CheckDigitScheme interface {
+	// Name identifies the scheme; it is part of the FPE tweak.
+	Name() string
+
+	// Alphabet is the set of payload characters.
+	Alphabet() *Alphabet
+
+	// CheckLen is the number of trailing check characters.
+	CheckLen() int
+
+	// Compute returns the check characters for payload. It returns
+	// ErrNoCheckDigit for payloads that have none (e.g. mod-11 schemes
+	// whose remainder 10 has no digit) and an error for payloads the
+	// scheme does not accept, such as a wrong length.
+	Compute(payload string) (string, error)
+}
+
+// ErrNoCheckDigit is returned by CheckDigitScheme.Compute for payloads
+// that have no valid check characters.
+var ErrNoCheckDigit = errors.New("payload has no check digit")
+
+// ErrCheckDigit is returned for identifiers whose check characters are wrong.
+var ErrCheckDigit = errors.New("check digit mismatch")
+
+// ValidCheckDigit reports whether s (without separators) ends in the
+// check characters scheme computes for the rest of it.
+func ValidCheckDigit(scheme CheckDigitScheme, s string) bool {
+	k := scheme.CheckLen()
+	runes := []rune(s)
+	if len(runes) <= k {
+		return false
+	}
+	payload := string(runes[:len(runes)-k])
+	for _, r := range payload {
+		if !scheme.Alphabet().Contains(r) {
+			return false
+		}
+	}
+	check, err := scheme.Compute(payload)
+	return err == nil && check == string(runes[len(runes)-k:])
+}
+
+// Built-in schemes.
+var (
+	// Luhn is the mod-10 check of card numbers and IMEIs.
+	Luhn CheckDigitScheme = luhnScheme{}
+
+	// Verhoeff is the dihedral-group check used e.g. by Aadhaar numbers.
+	Verhoeff CheckDigitScheme = verhoeffScheme{}
+
+	// ISBN10 is the mod-11 check of 10-character ISBNs; 10 is written 'X'.
+	ISBN10 CheckDigitScheme = isbn10Scheme{}
+
+	// EAN13 is the GS1 check of 13-digit EAN barcodes.
+	EAN13 CheckDigitScheme = gtinScheme{name: "ean13", length: 13}
+
+	// ISBN13 is the EAN-13 check of 13-digit ISBNs.
+	ISBN13 CheckDigitScheme = gtinScheme{name: "isbn13", length: 13}
+
+	// Mod97 is ISO 7064 MOD 97-10 over a digit payload, with two
+	// trailing check digits. IBANs and LEIs use the same check but over
+	// letters too, which are rewritten as 10..35 first, so they cannot be
+	// passed to EncryptCheckDigit as they are; EncryptIBAN does the
+	// rewriting and checks IBANs with Mod97.
+	Mod97 CheckDigitScheme = mod97Scheme{}
+)
+
+// ---- Luhn ----
+
+type luhnScheme struct{}
+
+func (luhnScheme) Name() string        { return "luhn" }
+func (luhnScheme) Alphabet() *Alphabet { return AlphabetDigits }
+func (luhnScheme) CheckLen() int       { return 1 }
+
+func (luhnScheme) Compute(payload string) (string, error) {
+	if !isDigits(payload) {
+		return "", errors.New("luhn: payload is not digits")
+	}
+	digits := append([]byte(payload), '0')
+	return string(luhnFix(digits, len(digits)-1)), nil
+}
+
+// luhnSum is the Luhn sum of digits, skipping position skip.
+func luhnSum(digits []byte, skip int) int {
+	sum := 0
+	for i := range digits {
+		if i != skip {
+			sum += luhnValue(digits[i]-'0', len(digits)-1-i)
+		}
+	}
+	return sum
+}
+
+// luhnValue is the contribution of digit d at distance pos from the right.
+func luhnValue(d byte, pos int) int {
+	v := int(d)
+	if pos%2 == 1 {
+		v *= 2
+		if v > 9 {
+			v -= 9
+		}
+	}
+	return v
+}
+
+// luhnFix returns the digit for position i that makes digits Luhn-valid.
+func luhnFix(digits []byte, i int) byte {
+	sum := luhnSum(digits, i)
+	pos := len(digits) - 1 - i
+	for d := byte(0); d < 10; d++ {
+		if (sum+luhnValue(d, pos))%10 == 0 {
+			return '0' + d
+		}
+	}
+	panic("unreachable") // luhnValue is a bijection on 0..9
+}
+
+// ---- Verhoeff ----
+
+type verhoeffScheme struct{}
+
+var (
+	verhoeffD = [10][10]byte{
+		{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		{1, 2, 3, 4, 0, 6, 7, 8, 9, 5},
+		{2, 3, 4, 0, 1, 7, 8, 9, 5, 6},
+		{3, 4, 0, 1, 2, 8, 9, 5, 6, 7},
+		{4, 0, 1, 2, 3, 9, 5, 6, 7, 8},
+		{5, 9, 8, 7, 6, 0, 4, 3, 2, 1},
+		{6, 5, 9, 8, 7, 1, 0, 4, 3, 2},
+		{7, 6, 5, 9, 8, 2, 1, 0, 4, 3},
+		{8, 7, 6, 5, 9, 3, 2, 1, 0, 4},
+		{9, 8, 7, 6, 5, 4, 3, 2, 1, 0},
+	}
+	verhoeffP = [8][10]byte{
+		{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		{1, 5, 7, 6, 2, 8, 3, 0, 9, 4},
+		{5, 8, 0, 3, 7, 9, 6, 1, 4, 2},
+		{8, 9, 1, 6, 0, 4, 3, 5, 2, 7},
+		{9, 4, 5, 3, 1, 2, 6, 8, 7, 0},
+		{4, 2, 8, 6, 5, 7, 3, 9, 0, 1},
+		{2, 7, 9, 3, 8, 0, 6, 4, 1, 5},
+		{7, 0, 4, 6, 9, 1, 3, 2, 5, 8},
+	}
+	verhoeffInv = [10]byte{0, 4, 3, 2, 1, 5, 6, 7, 8, 9}
+)
+
+func (verhoeffScheme) Name() string        { return "verhoeff" }
+func (verhoeffScheme) Alphabet() *Alphabet { return AlphabetDigits }
+func (verhoeffScheme) CheckLen() int       { return 1 }
+
+func (verhoeffScheme) Compute(payload string) (string, error) {
+	if !isDigits(payload) {
+		return "", errors.New("verhoeff: payload is not digits")
+	}
+	var c byte
+	for i := 0; i < len(payload); i++ {
+		d := payload[len(payload)-1-i] - '0'
+		c = verhoeffD[c][verhoeffP[(i+1)%8][d]]
+	}
+	return string('0' + verhoeffInv[c]), nil
+}
+
+// ---- ISBN-10 ----
+
+type isbn10Scheme struct{}
+
+func (isbn10Scheme) Name() string        { return "isbn10" }
+func (isbn10Scheme) Alphabet() *Alphabet { return AlphabetDigits }
+func (isbn10Scheme) CheckLen() int       { return 1 }
+
+func (isbn10Scheme) Compute(payload string) (string, error) {
+	if len(payload) != 9 || !isDigits(payload) {
+		return "", errors.New("isbn10: payload must be 9 digits")
+	}
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += (10 - i) * int(payload[i]-'0')
+	}
+	switch c := (11 - sum%11) % 11; c {
+	case 10:
+		return "X", nil
+	default:
+		return string(rune('0' + c)), nil
+	}
+}
+
+// ---- EAN / GTIN ----
+
+// gtinScheme is the GS1 check: weights 3, 1, 3, ... from the right of the
+// payload, check digit = -sum mod 10.
+type gtinScheme struct {
+	name   string
+	length int // including the check digit
+}
+
+func (g gtinScheme) Name() string      { return g.name }
+func (gtinScheme) Alphabet() *Alphabet { return AlphabetDigits }
+func (gtinScheme) CheckLen() int       { return 1 }
+
+func (g gtinScheme) Compute(payload string) (string, error) {
+	if len(payload) != g.length-1 || !isDigits(payload) {
+		return "", fmt.Errorf("%s: payload must be %d digits", g.name, g.length-1)
+	}
+	sum := 0
+	for i := 0; i < len(payload); i++ {
+		w := 1
+		if i%2 == 0 {
+			w = 3
+		}
+		sum += w * int(payload[len(payload)-1-i]-'0')
+	}
+	return string(rune('0' + (10-sum%10)%10)), nil
+}
+
+// ---- mod 11 ----
+
+// Mod11 is the weighted mod-11 check used by many national ID and account
+// numbers: the payload digits, from the right, are multiplied by Weights
+// (repeated as needed; 2, 3, 4, 5, 6, 7 when empty) and the check digit is
+// 11 - sum mod 11, with 11 written as 0. A result of 10 has no digit, so
+// such payloads are not valid identifiers (ErrNoCheckDigit).
+type Mod11 struct {
+	Weights []int
+}
+
+var mod11Weights = []int{2, 3, 4, 5, 6, 7}
+
+// Name includes the weights, so differently weighted schemes get
+// different tweaks.
+func (m Mod11) Name() string { return fmt.Sprint("mod11", m.weights()) }
+
+func (Mod11) Alphabet() *Alphabet { return AlphabetDigits }
+func (Mod11) CheckLen() int       { return 1 }
+
+func (m Mod11) weights() []int {
+	if len(m.Weights) == 0 {
+		return mod11Weights
+	}
+	return m.Weights
+}
+
+func (m Mod11) Compute(payload string) (string, error) {
+	if !isDigits(payload) {
+		return "", errors.New("mod11: payload is not digits")
+	}
+	w := m.weights()
+	sum := 0
+	for i := 0; i < len(payload); i++ {
+		sum += w[i%len(w)] * int(payload[len(payload)-1-i]-'0')
+	}
+	switch c := (11 - sum%11) % 11; c {
+	case 10:
+		return "", ErrNoCheckDigit
+	default:
+		return string(rune('0' + c)), nil
+	}
+}
+
+// ---- ISO 7064 MOD 97-10 ----
+
+type mod97Scheme struct{}
+
+func (mod97Scheme) Name() string        { return "mod97" }
+func (mod97Scheme) Alphabet() *Alphabet { return AlphabetDigits }
+func (mod97Scheme) CheckLen() int       { return 2 }
+
+func (mod97Scheme) Compute(payload string) (string, error) {
+	if !isDigits(payload) {
+		return "", errors.New("mod97: payload is not digits")
+	}
+	return fmt.Sprintf("%02d", 98-mod97(payload+"00")), nil
+}
+
+// mod97 returns the digit string s modulo 97.
+func mod97(s string) int {
+	r := 0
+	for i := 0; i < len(s); i++ {
+		r = (r*10 + int(s[i]-'0')) % 97
+	}
+	return r
+}
+
+// isDigits reports whether s is a non-empty string of ASCII digits.
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isDigit(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// ---------------------------
+// FPE with check digits
+// ---------------------------
+
+// CheckDigitOptions configures EncryptCheckDigit.
+type CheckDigitOptions struct {
+	// Tweak is bound next to the scheme, so the same body encrypts
+	// differently under each caller tweak; see MaxTweakLen.
+	Tweak []byte
+}
+
+// EncryptCheckDigit encrypts an identifier that ends in check characters:
+// the check is stripped, the payload is encrypted as one domain over the
+// scheme's alphabet and the check is recomputed, so the result is valid
+// under scheme again. Spaces and dashes are kept in place, as in
+// "978-3-16-148410-0". The input must be valid (ErrCheckDigit otherwise).
+// Every payload character is encrypted, prefixes such as the 978 of an
+// ISBN-13 included.
+//
+// Payloads that have no check characters (see ErrNoCheckDigit) are
+// skipped by cycle-walking, so every ciphertext is a valid identifier and
+// decryption walks back the same way.
+func (c *fpeCore) EncryptCheckDigit(s string, scheme CheckDigitScheme, opts CheckDigitOptions) (string, error) {
+	return c.checkDigit(s, scheme, opts.Tweak, true)
+}
+
+// DecryptCheckDigit is the inverse of EncryptCheckDigit with the same
+// options.
+func (c *fpeCore) DecryptCheckDigit(s string, scheme CheckDigitScheme, opts CheckDigitOptions) (string, error) {
+	return c.checkDigit(s, scheme, opts.Tweak, false)
+}
+
+func (c *fpeCore) checkDigit(s string, scheme CheckDigitScheme, tweak []byte, encrypt bool) (string, error) {
+	if scheme == nil {
+		return "", errors.New("nil check-digit scheme")
+	}
+	if len(tweak) > MaxTweakLen {
+		return "", ErrTweakTooLong
+	}
+	if !utf8.ValidString(s) {
+		return "", errors.New("identifier is not valid UTF-8")
+	}
+	runes := []rune(s)
+	var chars []rune
+	for _, r := range runes {
+		if r != ' ' && r != '-' {
+			chars = append(chars, r)
+		}
+	}
+	if !ValidCheckDigit(scheme, string(chars)) {
+		return "", ErrCheckDigit
+	}
+
+	a := scheme.Alphabet()
+	n := len(chars) - scheme.CheckLen()
+	vals := make([]int, n)
+	radices := make([]int, n)
+	for i := range vals {
+		vals[i] = a.Index(chars[i])
+		radices[i] = a.Radix()
+	}
+	sum := sha256.Sum256([]byte(scheme.Name()))
+	t := classTweak(append([]byte("K-"), sum[:5]...), tweak)
+
+	var check string
+	for walks := 0; ; walks++ {
+		if walks > maxCycleWalks {
+			return "", ErrCycleWalkLimit
+		}
+		var err error
+		if vals, err = c.permuteNumerals(vals, radices, t, encrypt); err != nil {
+			return "", err
+		}
+		payload := make([]rune, n)
+		for i, v := range vals {
+			payload[i] = a.Rune(v)
+		}
+		check, err = scheme.Compute(string(payload))
+		if err == nil {
+			copy(chars, payload)
+			break
+		}
+		if !errors.Is(err, ErrNoCheckDigit) {
+			return "", err
+		}
+	}
+	copy(chars[n:], []rune(check))
+
+	var out strings.Builder
+	out.Grow(len(s))
+	j := 0
+	for _, r := range runes {
+		if r == ' ' || r == '-' {
+			out.WriteRune(r)
+		} else {
+			out.WriteRune(chars[j])
+			j++
+		}
+	}
+	return out.String(), nil
+}
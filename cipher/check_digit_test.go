@@ -0,0 +1,82 @@
+package cipher
+
+import (
+	"errors"
+	"testing"
+)
+
+// checkDigitCases are valid identifiers for each built-in scheme.
+var checkDigitCases = []struct {
+	scheme CheckDigitScheme
+	valid  []string
+}{
+	{Luhn, []string{"79927398713", "4111111111111111"}},
+	{Verhoeff, []string{"2363", "12345678902"}},
+	{ISBN10, []string{"0306406152", "080442957X"}},
+	{EAN13, []string{"4006381333931", "5901234123457"}},
+	{ISBN13, []string{"9780306406157", "9783161484100"}},
+	{Mod97, []string{"79444", "123456751"}},
+	{Mod11{}, []string{"12345674", "00"}},
+	{Mod11{Weights: []int{3, 2, 7, 6, 5, 4, 3, 2}}, []string{"123456784", "876543215"}},
+}
+
+func TestCheckDigitSchemes(t *testing.T) {
+	for _, tc := range checkDigitCases {
+		for _, s := range tc.valid {
+			if !ValidCheckDigit(tc.scheme, s) {
+				t.Errorf("%s: %q is not valid", tc.scheme.Name(), s)
+			}
+			// a changed check character must be rejected
+			b := []byte(s)
+			if b[len(b)-1] == '1' {
+				b[len(b)-1] = '2'
+			} else {
+				b[len(b)-1] = '1'
+			}
+			if ValidCheckDigit(tc.scheme, string(b)) {
+				t.Errorf("%s: %q is valid", tc.scheme.Name(), b)
+			}
+		}
+		if _, err := tc.scheme.Compute("12a"); err == nil {
+			t.Errorf("%s: Compute accepted a non-digit payload", tc.scheme.Name())
+		}
+	}
+}
+
+func TestMod11NoCheckDigit(t *testing.T) {
+	// 6*2 = 12 = 1 mod 11, so the check would be 10
+	if _, err := (Mod11{}).Compute("6"); !errors.Is(err, ErrNoCheckDigit) {
+		t.Errorf("Mod11.Compute(6) error = %v, want ErrNoCheckDigit", err)
+	}
+}
+
+func TestEncryptCheckDigit(t *testing.T) {
+	for name, c := range fpeCores(t) {
+		for _, tc := range checkDigitCases {
+			for _, s := range tc.valid {
+				enc, err := c.EncryptCheckDigit(s, tc.scheme, CheckDigitOptions{})
+				if err != nil {
+					t.Fatalf("%s: %s: EncryptCheckDigit(%q): %v", name, tc.scheme.Name(), s, err)
+				}
+				if len(enc) != len(s) || !ValidCheckDigit(tc.scheme, enc) {
+					t.Errorf("%s: %s: %q encrypts to invalid %q", name, tc.scheme.Name(), s, enc)
+				}
+				dec, err := c.DecryptCheckDigit(enc, tc.scheme, CheckDigitOptions{})
+				if err != nil || dec != s {
+					t.Errorf("%s: %s: DecryptCheckDigit(%q) = %q, %v; want %q", name, tc.scheme.Name(), enc, dec, err, s)
+				}
+			}
+		}
+		// separators stay in place
+		enc, err := c.EncryptCheckDigit("978-3-16-148410-0", ISBN13, CheckDigitOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if enc[3] != '-' || enc[5] != '-' || enc[8] != '-' || enc[15] != '-' {
+			t.Errorf("%s: separators moved: %q", name, enc)
+		}
+		if _, err := c.EncryptCheckDigit("79927398710", Luhn, CheckDigitOptions{}); !errors.Is(err, ErrCheckDigit) {
+			t.Errorf("%s: invalid input error = %v, want ErrCheckDigit", name, err)
+		}
+	}
+}
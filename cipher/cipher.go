@@ -1,8 +1,156 @@
 package cipher
 
+import (
+	"context"
+	"errors"
+)
+
 type Cipher interface {
 	Encrypt(text string) string
 	Decrypt(text string) string
 	EncryptNumber(text string) string
 	DecryptNumber(text string) string
 }
+
+// CipherV2 is the error-returning successor of Cipher.
+// Every call takes a context so long batches can be cancelled and
+// per-call settings can travel with the request.
+type CipherV2 interface {
+	EncryptContext(ctx context.Context, text string) (string, error)
+	DecryptContext(ctx context.Context, text string) (string, error)
+	EncryptNumberContext(ctx context.Context, text string) (string, error)
+	DecryptNumberContext(ctx context.Context, text string) (string, error)
+}
+
+// NumericMode selects how the digits of a number are encrypted.
+type NumericMode int
+
+const (
+	// NumericNoLeadingZero keeps whether a number starts with '0':
+	// "12345" encrypts to a 5-digit string without a leading zero, and
+	// "01234" to a 5-digit string that starts with '0'. Both halves are
+	// permuted separately, so both round-trip. This is the default.
+	NumericNoLeadingZero NumericMode = iota
+
+	// NumericFixedLength treats the digits as a fixed-length code such as
+	// a zip code or account number: one permutation over all 10^len
+	// strings, so leading zeros may appear or disappear.
+	NumericFixedLength
+)
+
+// ErrNotNumber is returned by the number methods of CipherV2 when the
+// input is not an optionally signed string of decimal digits.
+var ErrNotNumber = errors.New("not a decimal number")
+
+// isNumber reports whether s is an optional '-' followed by one or more digits.
+func isNumber(s string) bool {
+	if len(s) > 0 && s[0] == '-' {
+		s = s[1:]
+	}
+	if len(s) == 0 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isDigit(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// ---------------------------
+// adapters
+// ---------------------------
+
+// AsCipher exposes a CipherV2 through the legacy Cipher interface.
+// Ciphers that already implement Cipher are returned unchanged. For the
+// others, the legacy methods cannot report errors, so a failed call
+// returns "" rather than echoing the (possibly plaintext) input.
+func AsCipher(c CipherV2) Cipher {
+	if legacy, ok := c.(Cipher); ok {
+		return legacy
+	}
+	if legacy, ok := c.(v2Adapter); ok {
+		return legacy.c
+	}
+	return legacyAdapter{c: c}
+}
+
+// AsCipherV2 exposes a legacy Cipher through CipherV2.
+// Ciphers that already implement CipherV2 are returned unchanged.
+func AsCipherV2(c Cipher) CipherV2 {
+	if v2, ok := c.(CipherV2); ok {
+		return v2
+	}
+	if legacy, ok := c.(legacyAdapter); ok {
+		return legacy.c
+	}
+	return v2Adapter{c: c}
+}
+
+type legacyAdapter struct{ c CipherV2 }
+
+func (a legacyAdapter) Encrypt(s string) string {
+	return orEmpty(a.c.EncryptContext(context.Background(), s))
+}
+
+func (a legacyAdapter) Decrypt(s string) string {
+	return orEmpty(a.c.DecryptContext(context.Background(), s))
+}
+
+func (a legacyAdapter) EncryptNumber(s string) string {
+	return orEmpty(a.c.EncryptNumberContext(context.Background(), s))
+}
+
+func (a legacyAdapter) DecryptNumber(s string) string {
+	return orEmpty(a.c.DecryptNumberContext(context.Background(), s))
+}
+
+func orEmpty(s string, err error) string {
+	if err != nil {
+		return ""
+	}
+	return s
+}
+
+type v2Adapter struct{ c Cipher }
+
+func (a v2Adapter) EncryptContext(ctx context.Context, s string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return a.c.Encrypt(s), nil
+}
+
+func (a v2Adapter) DecryptContext(ctx context.Context, s string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return a.c.Decrypt(s), nil
+}
+
+func (a v2Adapter) EncryptNumberContext(ctx context.Context, s string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if !isNumber(s) {
+		return "", ErrNotNumber
+	}
+	return a.c.EncryptNumber(s), nil
+}
+
+func (a v2Adapter) DecryptNumberContext(ctx context.Context, s string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if !isNumber(s) {
+		return "", ErrNotNumber
+	}
+	return a.c.DecryptNumber(s), nil
+}
+
+var (
+	_ CipherV2 = (*SubstitutionCipher)(nil)
+	_ CipherV2 = (*FPECipher)(nil)
+	_ CipherV2 = (*FF31Cipher)(nil)
+)
@@ -0,0 +1,95 @@
+package cipher
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestAsCipherKeepsLegacyCipher(t *testing.T) {
+	sub := NewSubstitutionCipher("key").(*SubstitutionCipher)
+	legacy := AsCipher(sub)
+	if legacy != Cipher(sub) {
+		t.Fatalf("AsCipher(%T) = %T, want the cipher itself", sub, legacy)
+	}
+	if got := legacy.EncryptNumber("12a"); got != "12a" {
+		t.Errorf("EncryptNumber(%q) = %q, want the input back", "12a", got)
+	}
+}
+
+func TestAsCipherRoundTrip(t *testing.T) {
+	sub := NewSubstitutionCipher("key")
+	if got := AsCipher(AsCipherV2(sub)); got != sub {
+		t.Errorf("AsCipher(AsCipherV2(c)) = %T, want c", got)
+	}
+	fpe, err := NewFPECipher(make([]byte, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := AsCipherV2(AsCipher(fpe)); got != CipherV2(fpe) {
+		t.Errorf("AsCipherV2(AsCipher(c)) = %T, want c", got)
+	}
+}
+
+// TestNumericModesExhaustive encrypts every digit string of length 1-3 and
+// checks that each mode is a permutation of the strings of that length
+// and that NumericNoLeadingZero keeps the leading-'0' class.
+func TestNumericModesExhaustive(t *testing.T) {
+	ff1, err := NewFPECipher(make([]byte, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ff31, err := NewFF31Cipher(make([]byte, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := NewSubstitutionCipher("key").(*SubstitutionCipher)
+
+	type codec struct {
+		name             string
+		encrypt, decrypt func(string, NumericMode) (string, error)
+	}
+	preserving := func(name string, c *fpeCore) codec {
+		return codec{name,
+			func(s string, m NumericMode) (string, error) {
+				return c.EncryptPreservingWith(s, PreserveOptions{Numeric: m})
+			},
+			func(s string, m NumericMode) (string, error) {
+				return c.DecryptPreservingWith(s, PreserveOptions{Numeric: m})
+			}}
+	}
+	codecs := []codec{
+		preserving("FF1", ff1.fpeCore),
+		preserving("FF3-1", ff31.fpeCore),
+		{"substitution",
+			func(s string, m NumericMode) (string, error) { return sub.EncryptNumberMode(s, m), nil },
+			func(s string, m NumericMode) (string, error) { return sub.DecryptNumberMode(s, m), nil }},
+	}
+
+	for _, c := range codecs {
+		for _, mode := range []NumericMode{NumericNoLeadingZero, NumericFixedLength} {
+			for n, size := 1, 10; n <= 3; n, size = n+1, size*10 {
+				seen := make(map[string]string, size)
+				for v := 0; v < size; v++ {
+					x := fmt.Sprintf("%0*d", n, v)
+					y, err := c.encrypt(x, mode)
+					if err != nil {
+						t.Fatalf("%s mode %d: encrypt %q: %v", c.name, mode, x, err)
+					}
+					if len(y) != n || !isNumber(y) {
+						t.Fatalf("%s mode %d: %q encrypts to %q", c.name, mode, x, y)
+					}
+					if prev, dup := seen[y]; dup {
+						t.Fatalf("%s mode %d: %q and %q both encrypt to %q", c.name, mode, prev, x, y)
+					}
+					seen[y] = x
+					if mode == NumericNoLeadingZero && (x[0] == '0') != (y[0] == '0') {
+						t.Errorf("%s: %q encrypts to %q, leading-zero class changed", c.name, x, y)
+					}
+					if back, err := c.decrypt(y, mode); err != nil || back != x {
+						t.Errorf("%s mode %d: %q decrypts to %q, %v; want %q", c.name, mode, y, back, err, x)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package cipher
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/capitalone/fpe/ff3"
+)
+
+// ---------------------------
+// FPE cipher (FF3-1) per class
+// ---------------------------
+
+// FF31Cipher is the NIST SP 800-38G Rev. 1 FF3-1 counterpart of FPECipher.
+// It segments input exactly like FPECipher; only the primitive differs.
+//
+// Unlike FF1, FF3-1 bounds the length of one run: fewer than
+// 192/log2(radix) numerals, so at most 56 digits, 39 letters or 191 bits.
+// EncryptPreserving of a longer run, such as a 60-digit number, fails
+// with an error where FPECipher succeeds. Runs shorter than the Rev. 1
+// minimum domain use the small-domain permutation instead.
+type FF31Cipher struct {
+	*fpeCore
+}
+
+// NewFF31Cipher builds FF3-1 ciphers for digits/upper/lower.
+// key must be 16, 24, or 32 bytes (AES-128/192/256).
+func NewFF31Cipher(key []byte) (*FF31Cipher, error) {
+	core, err := newFPECore(key, "ff3-1", ff31MinDomain, func(radix int) (ffx, error) {
+		return newFF31(radix, key)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &FF31Cipher{core}, nil
+}
+
+// ff31MinDomain is the Rev. 1 requirement radix^minlen >= 1,000,000.
+const ff31MinDomain = 1000000
+
+// ff31TweakLen is the FF3-1 tweak size (56 bits).
+const ff31TweakLen = 7
+
+// ff31 adapts the library's FF3 to FF3-1: a 56-bit tweak and the larger
+// minimum domain. The Feistel rounds themselves are unchanged in Rev. 1.
+// Tweaks that are not exactly 7 bytes (class tweak + caller tweak) are
+// compressed to 7 bytes with SHA-256.
+type ff31 struct {
+	c      ff3.Cipher
+	minLen int
+	maxLen int // exclusive, as the library checks it
+}
+
+func newFF31(radix int, key []byte) (ff31, error) {
+	c, err := ff3.NewCipher(radix, key, make([]byte, 8))
+	if err != nil {
+		return ff31{}, err
+	}
+	minLen := int(math.Ceil(math.Log(ff31MinDomain) / math.Log(float64(radix))))
+	maxLen := int(math.Floor(192 / math.Log2(float64(radix))))
+	return ff31{c: c, minLen: minLen, maxLen: maxLen}, nil
+}
+
+func (f ff31) EncryptWithTweak(X string, tweak []byte) (string, error) {
+	T, err := f.check(X, tweak)
+	if err != nil {
+		return "", err
+	}
+	return f.c.EncryptWithTweak(X, T)
+}
+
+func (f ff31) DecryptWithTweak(X string, tweak []byte) (string, error) {
+	T, err := f.check(X, tweak)
+	if err != nil {
+		return "", err
+	}
+	return f.c.DecryptWithTweak(X, T)
+}
+
+func (f ff31) check(X string, tweak []byte) ([]byte, error) {
+	if len(X) < f.minLen {
+		return nil, errors.New("message length is below the FF3-1 minimum domain")
+	}
+	if len(X) >= f.maxLen {
+		return nil, fmt.Errorf("message length %d is above the FF3-1 maximum of %d numerals", len(X), f.maxLen-1)
+	}
+	if len(tweak) != ff31TweakLen {
+		sum := sha256.Sum256(tweak)
+		tweak = sum[:ff31TweakLen]
+	}
+	return ff31Tweak64(tweak), nil
+}
+
+// ff31Tweak64 expands a 56-bit FF3-1 tweak T into the 64-bit FF3 tweak
+// TL || TR with TL = T[0..27] || 0^4 and TR = T[32..55] || T[28..31] || 0^4.
+func ff31Tweak64(T []byte) []byte {
+	return []byte{
+		T[0], T[1], T[2], T[3] & 0xF0,
+		T[4], T[5], T[6], (T[3] & 0x0F) << 4,
+	}
+}
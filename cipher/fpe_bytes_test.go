@@ -0,0 +1,68 @@
+package cipher
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestBytesRoundTrip(t *testing.T) {
+	c, err := NewFPECipher(make([]byte, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rng := rand.New(rand.NewSource(1))
+	for _, n := range []int{0, 1, 2, 255, 256, 257, 511, 1000} {
+		b := make([]byte, n)
+		rng.Read(b)
+		orig := append([]byte(nil), b...)
+		for _, opts := range []BytesOptions{{}, {Tweak: []byte("tenant-1")}} {
+			enc, err := c.EncryptBytes(b, opts)
+			if err != nil {
+				t.Fatalf("EncryptBytes(%d bytes): %v", n, err)
+			}
+			if !bytes.Equal(b, orig) {
+				t.Fatalf("EncryptBytes modified its input")
+			}
+			if len(enc) != n || (n > 1 && bytes.Equal(enc, b)) {
+				t.Errorf("EncryptBytes(%d bytes) returned %d bytes, or the input", n, len(enc))
+			}
+			dec, err := c.DecryptBytes(enc, opts)
+			if err != nil || !bytes.Equal(dec, b) {
+				t.Errorf("DecryptBytes(%d bytes) did not round-trip: %v", n, err)
+			}
+		}
+	}
+}
+
+// TestBytesDiffusion checks that across chunks every output byte depends
+// on every input byte: a change in the first chunk reaches the last
+// output chunk and the other way round.
+func TestBytesDiffusion(t *testing.T) {
+	c, err := NewFPECipher(make([]byte, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rng := rand.New(rand.NewSource(2))
+	for _, n := range []int{257, 1000} {
+		b := make([]byte, n)
+		rng.Read(b)
+		chunks := splitChunks(make([]byte, n), bytesChunk)
+		first, last := len(chunks[0]), n-len(chunks[len(chunks)-1])
+		enc, _ := c.EncryptBytes(b, BytesOptions{})
+		for _, i := range []int{0, first - 1, last, n - 1} {
+			flipped := append([]byte(nil), b...)
+			flipped[i] ^= 1
+			e, err := c.EncryptBytes(flipped, BytesOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Equal(e[:first], enc[:first]) {
+				t.Errorf("%d bytes: flipping byte %d leaves the first output chunk unchanged", n, i)
+			}
+			if bytes.Equal(e[last:], enc[last:]) {
+				t.Errorf("%d bytes: flipping byte %d leaves the last output chunk unchanged", n, i)
+			}
+		}
+	}
+}
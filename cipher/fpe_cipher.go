@@ -1,8 +1,12 @@
 package cipher
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
 	"errors"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/capitalone/fpe/ff1"
 )
@@ -11,59 +15,126 @@ import (
 // helpers: char classes
 // ---------------------------
 func isDigit(b byte) bool { return '0' <= b && b <= '9' }
-func isUpper(b byte) bool { return 'A' <= b && b <= 'Z' }
-func isLower(b byte) bool { return 'a' <= b && b <= 'z' }
 
-// digits used by ff1 (0..35 -> '0'..'9','A'..'Z')
-var digits36 = []byte("0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+// numerals used by the ff1/ff3 libraries (math/big digit order).
+// For radix <= 36 the libraries emit lowercase and accept either case.
+var numerals = []byte("0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
 
-// map 0..25 -> single-char digit for radix 26 (0..9,A..P)
-func encRadix26(v int) byte { return digits36[v] } // v in [0,25]
+// ffx is the tweakable FPE primitive (FF1 or FF3-1) behind one char class.
+type ffx interface {
+	EncryptWithTweak(X string, tweak []byte) (string, error)
+	DecryptWithTweak(X string, tweak []byte) (string, error)
+}
+
+// NIST FF1/FF3-1 allow a tweak (like a nonce/salt). Keep short & constant per domain.
+// Class tweaks are prefix-free (these three, and DeriveTweak for custom
+// classes) and a caller tweak is appended length-prefixed, so classes stay
+// separated from each other under every caller tweak.
+var (
+	tweakD = []byte("D-TWEAK") // exactly 7 bytes, so FF3-1 can use them as-is
+	tweakU = []byte("U-TWEAK")
+	tweakL = []byte("L-TWEAK")
+)
 
-// map digit char ('0'..'9','A'..'P') -> 0..25
-func decRadix26(ch byte) (int, error) {
-	if ch >= '0' && ch <= '9' {
-		return int(ch - '0'), nil
+// MaxTweakLen bounds caller-supplied tweaks. Every mode derives a fixed
+// tweak of its own; a non-nil caller tweak (the Tweak field of the mode's
+// options, see DeriveTweak) follows it with its length in front, so a
+// ciphertext only decrypts under the same caller tweak. nil and an empty
+// tweak differ: nil leaves the mode's tweak alone.
+const MaxTweakLen = 1 << 16
+
+// ErrTweakTooLong is returned for tweaks longer than MaxTweakLen.
+var ErrTweakTooLong = errors.New("tweak longer than MaxTweakLen")
+
+// classTweak returns the tweak for one char class under a caller tweak:
+// the class tweak, then the caller tweak with its length in front, so no
+// (class, tweak) pair runs into another. Without a caller tweak it is the
+// class tweak alone.
+func classTweak(class, tweak []byte) []byte {
+	if tweak == nil {
+		return class
 	}
-	if ch >= 'A' && ch <= 'P' {
-		return 10 + int(ch-'A'), nil
+	t := make([]byte, 0, len(class)+binary.MaxVarintLen64+len(tweak))
+	t = append(t, class...)
+	t = binary.AppendUvarint(t, uint64(len(tweak)))
+	return append(t, tweak...)
+}
+
+// DeriveTweak builds a tweak from context such as a tenant ID, table and
+// column name. Each part is length-prefixed, so ("ab", "c") and ("a", "bc")
+// give different tweaks.
+func DeriveTweak(parts ...string) []byte {
+	var t []byte
+	for _, p := range parts {
+		t = binary.AppendUvarint(t, uint64(len(p)))
+		t = append(t, p...)
 	}
-	return 0, errors.New("invalid radix26 digit")
+	return t
+}
+
+type tweakKey struct{}
+
+// WithTweak returns a context that makes the CipherV2 methods of FPECipher
+// and FF31Cipher use tweak, the same as EncryptPreservingWithTweak.
+func WithTweak(ctx context.Context, tweak []byte) context.Context {
+	return context.WithValue(ctx, tweakKey{}, tweak)
+}
+
+// TweakFromContext returns the tweak stored by WithTweak, or nil.
+func TweakFromContext(ctx context.Context) []byte {
+	t, _ := ctx.Value(tweakKey{}).([]byte)
+	return t
 }
 
 // ---------------------------
 // FPE cipher (FF1) per class
 // ---------------------------
 type FPECipher struct {
-	ffDigits *ff1.Cipher // radix 10
-	ffUpper  *ff1.Cipher // radix 26 (A..Z)
-	ffLower  *ff1.Cipher // radix 26 (a..z)
+	*fpeCore
 }
 
 // NewFPECipher builds FF1 ciphers for digits/upper/lower.
 // key must be 16, 24, or 32 bytes (AES-128/192/256).
 func NewFPECipher(key []byte) (*FPECipher, error) {
-	if len(key) != 16 && len(key) != 24 && len(key) != 32 {
-		return nil, errors.New("key length must be 16, 24, or 32 bytes")
-	}
-	// NIST FF1 allows a tweak (like a nonce/salt). Keep short & constant per domain.
-	tweakD := []byte("D-TWEAK") // <= 8 bytes in this implementation
-	tweakU := []byte("U-TWEAK")
-	tweakL := []byte("L-TWEAK")
-
-	cD, err := ff1.NewCipher(10, 8, key, tweakD)
+	core, err := newFPECore(key, "ff1", ff1MinDomain, func(radix int) (ffx, error) {
+		c, err := ff1.NewCipher(radix, ff1MaxTweakLen, key, nil)
+		return ff1x{c}, err
+	})
 	if err != nil {
 		return nil, err
 	}
-	cU, err := ff1.NewCipher(26, 8, key, tweakU)
-	if err != nil {
-		return nil, err
-	}
-	cL, err := ff1.NewCipher(26, 8, key, tweakL)
-	if err != nil {
-		return nil, err
+	return &FPECipher{core}, nil
+}
+
+// ff1MinDomain is the radix^minlen >= 100 bound enforced by the ff1 library.
+const ff1MinDomain = 100
+
+// ff1MaxTweakLen is the longest tweak handed to the ff1 library as it is:
+// a fixed class tweak, the length prefix and a MaxTweakLen caller tweak.
+const ff1MaxTweakLen = len("D-TWEAK") + binary.MaxVarintLen64 + MaxTweakLen
+
+// ff1x wraps the library's FF1. Mode tweaks built with DeriveTweak (custom
+// class names, range bounds, IBAN bank codes) have no fixed length, so
+// tweaks longer than ff1MaxTweakLen are compressed with SHA-256; shorter
+// ones, including every tweak that worked before, are used unchanged.
+type ff1x struct {
+	c ff1.Cipher
+}
+
+func (f ff1x) EncryptWithTweak(X string, tweak []byte) (string, error) {
+	return f.c.EncryptWithTweak(X, ff1Tweak(tweak))
+}
+
+func (f ff1x) DecryptWithTweak(X string, tweak []byte) (string, error) {
+	return f.c.DecryptWithTweak(X, ff1Tweak(tweak))
+}
+
+func ff1Tweak(tweak []byte) []byte {
+	if len(tweak) <= ff1MaxTweakLen {
+		return tweak
 	}
-	return &FPECipher{ffDigits: &cD, ffUpper: &cU, ffLower: &cL}, nil
+	sum := sha256.Sum256(tweak)
+	return sum[:]
 }
 
 // ---------------------------
@@ -71,266 +142,405 @@ func NewFPECipher(key []byte) (*FPECipher, error) {
 // ---------------------------
 
 // EncryptPreserving:
-// - digits runs -> FF1 (radix10) with cycle-walking to avoid leading '0'
-// - uppercase runs -> FF1 (radix26) after mapping A..Z <-> 0..25
-// - lowercase runs -> FF1 (radix26) after mapping a..z <-> 0..25
-// - '-' before a digit run is preserved (signed numbers)
-// - other bytes are copied as-is
-func (c *FPECipher) EncryptPreserving(s string) (string, error) {
-	var out strings.Builder
-	for i := 0; i < len(s); {
-		b := s[i]
-
-		// signed number: keep '-' and encrypt following digits as one run
-		if b == '-' && i+1 < len(s) && isDigit(s[i+1]) {
-			out.WriteByte('-')
-			j := i + 1
-			for j < len(s) && isDigit(s[j]) {
-				j++
-			}
-			ct, err := c.encryptDigitsNoLeadingZero(s[i+1 : j])
-			if err != nil {
-				return "", err
-			}
-			out.WriteString(ct)
-			i = j
-			continue
-		}
+//   - digits runs -> radix10 with cycle-walking so that a leading '0' is
+//     neither added nor removed (see NumericMode)
+//   - uppercase runs -> radix26 after mapping A..Z <-> 0..25
+//   - lowercase runs -> radix26 after mapping a..z <-> 0..25
+//   - '-' before a digit run is preserved (signed numbers)
+//   - other bytes are copied as-is
+//   - classes added with RegisterClass take precedence over the above
+//
+// Small domains: FF1 needs radix^len >= 100 and FF3-1 needs
+// radix^len >= 1,000,000, so short runs (the "1" and "B" in "A1-B2", a
+// 4-digit run under FF3-1) cannot go through the primitive. Such runs are
+// encrypted with a keyed swap-or-not permutation of the whole run domain
+// (see smallPerm), under the same class and caller tweaks. The run
+// boundaries stay exactly as they are, so nothing merges or splits and
+// every value encrypts without errors.
+func (c *fpeCore) EncryptPreserving(s string) (string, error) {
+	return c.EncryptPreservingWith(s, PreserveOptions{})
+}
 
-		// digit run
-		if isDigit(b) {
-			j := i
-			for j < len(s) && isDigit(s[j]) {
-				j++
-			}
-			ct, err := c.encryptDigitsNoLeadingZero(s[i:j])
-			if err != nil {
-				return "", err
-			}
-			out.WriteString(ct)
-			i = j
-			continue
-		}
+// DecryptPreserving is the inverse of EncryptPreserving (same segmentation).
+func (c *fpeCore) DecryptPreserving(s string) (string, error) {
+	return c.DecryptPreservingWith(s, PreserveOptions{})
+}
 
-		// uppercase run
-		if isUpper(b) {
-			j := i
-			for j < len(s) && isUpper(s[j]) {
-				j++
-			}
-			ct, err := c.encryptLetters(s[i:j], true)
-			if err != nil {
-				return "", err
-			}
-			out.WriteString(ct)
-			i = j
-			continue
-		}
+// EncryptPreservingWithTweak is EncryptPreserving under a caller tweak,
+// e.g. one built with DeriveTweak from a tenant ID and column name.
+// The same value under two tweaks gives unrelated ciphertexts.
+func (c *fpeCore) EncryptPreservingWithTweak(s string, tweak []byte) (string, error) {
+	return c.EncryptPreservingWith(s, PreserveOptions{Tweak: tweak})
+}
 
-		// lowercase run
-		if isLower(b) {
-			j := i
-			for j < len(s) && isLower(s[j]) {
-				j++
-			}
-			ct, err := c.encryptLetters(s[i:j], false)
-			if err != nil {
-				return "", err
-			}
-			out.WriteString(ct)
-			i = j
-			continue
-		}
+// DecryptPreservingWithTweak is the inverse of EncryptPreservingWithTweak.
+func (c *fpeCore) DecryptPreservingWithTweak(s string, tweak []byte) (string, error) {
+	return c.DecryptPreservingWith(s, PreserveOptions{Tweak: tweak})
+}
+
+// PreserveOptions are per-call settings for EncryptPreservingWith.
+// The zero value gives the behavior of EncryptPreserving.
+type PreserveOptions struct {
+	// Tweak separates this data from other data under the same key, e.g.
+	// per column or tenant; see MaxTweakLen.
+	Tweak []byte
+
+	// Numeric selects how digit runs are encrypted. The default keeps
+	// whether a run starts with '0'; NumericFixedLength permutes all
+	// strings of the run's length, e.g. for zip codes or account numbers.
+	Numeric NumericMode
+
+	// Segmentation selects what one encryption domain is. The default
+	// encrypts each digit, uppercase and lowercase run on its own.
+	Segmentation Segmentation
+
+	// Classes says what an alphanumeric span keeps when Segmentation is
+	// not SegmentClassRuns. Class runs always keep their class.
+	Classes ClassPolicy
+
+	// Grouping treats digit groups joined by a thousands separator, as in
+	// "1,234,567", "1.234.567", "1 234 567" or "1'234'567", as one number:
+	// its digits are encrypted as one domain and the separators are kept
+	// where they are. It needs SegmentClassRuns. For amounts with a
+	// fraction, see EncryptDecimal.
+	Grouping bool
+}
+
+// Segmentation selects how EncryptPreservingWith splits a value into domains.
+type Segmentation int
+
+const (
+	// SegmentClassRuns encrypts digit, uppercase and lowercase runs
+	// separately. It keeps the most format but reveals where the class
+	// changes and produces many small domains.
+	SegmentClassRuns Segmentation = iota
+
+	// SegmentAlnum36 encrypts each maximal span of [0-9A-Z] as one
+	// radix-36 domain. Lowercase runs are still encrypted on their own.
+	SegmentAlnum36
+
+	// SegmentAlnum62 encrypts each maximal span of [0-9A-Za-z] as one
+	// radix-62 domain.
+	SegmentAlnum62
+)
+
+// ClassPolicy says what an alphanumeric span keeps besides its length.
+type ClassPolicy int
 
-		// passthrough
-		out.WriteByte(b)
-		i++
+const (
+	// PreserveClasses keeps the class (digit, uppercase, lowercase) of
+	// every position; the span is still one domain, the product of the
+	// per-position class sizes.
+	PreserveClasses ClassPolicy = iota
+
+	// PreserveLength keeps only the span length: any position may become
+	// any character of the span alphabet, hiding where classes change.
+	PreserveLength
+)
+
+// span tweaks, indexed by [Segmentation-1][ClassPolicy]
+var spanTweaks = [...][2][]byte{
+	{[]byte("S36-CLS"), []byte("S36-LEN")},
+	{[]byte("S62-CLS"), []byte("S62-LEN")},
+}
+
+// EncryptPreservingWith is EncryptPreserving with per-call options.
+func (c *fpeCore) EncryptPreservingWith(s string, opts PreserveOptions) (string, error) {
+	if err := opts.validate(); err != nil {
+		return "", err
 	}
-	return out.String(), nil
+	return c.preserve(s, opts, true)
 }
 
-// DecryptPreserving is the inverse of EncryptPreserving (same segmentation).
-func (c *FPECipher) DecryptPreserving(s string) (string, error) {
+// DecryptPreservingWith is the inverse of EncryptPreservingWith.
+func (c *fpeCore) DecryptPreservingWith(s string, opts PreserveOptions) (string, error) {
+	if err := opts.validate(); err != nil {
+		return "", err
+	}
+	return c.preserve(s, opts, false)
+}
+
+func (o PreserveOptions) validate() error {
+	if len(o.Tweak) > MaxTweakLen {
+		return ErrTweakTooLong
+	}
+	if o.Segmentation < SegmentClassRuns || o.Segmentation > SegmentAlnum62 {
+		return errors.New("unknown segmentation")
+	}
+	if o.Classes != PreserveClasses && o.Classes != PreserveLength {
+		return errors.New("unknown class policy")
+	}
+	if o.Grouping && o.Segmentation != SegmentClassRuns {
+		return errors.New("grouping needs SegmentClassRuns")
+	}
+	return nil
+}
+
+// preserve splits s into class runs and encrypts (or decrypts) each run
+// with the primitive of its class. Encryption never moves a run boundary,
+// so decryption finds exactly the same runs.
+func (c *fpeCore) preserve(s string, opts PreserveOptions, encrypt bool) (string, error) {
+	cs := c.classes.Load()
+	var span *Alphabet
+	if opts.Segmentation != SegmentClassRuns {
+		span = cs.spans[opts.Segmentation-1]
+	}
+
 	var out strings.Builder
 	for i := 0; i < len(s); {
-		b := s[i]
+		r, size := utf8.DecodeRuneInString(s[i:])
 
-		if b == '-' && i+1 < len(s) && isDigit(s[i+1]) {
-			out.WriteByte('-')
-			j := i + 1
-			for j < len(s) && isDigit(s[j]) {
-				j++
-			}
-			pt, err := c.decryptDigitsNoLeadingZero(s[i+1 : j])
+		// custom class run
+		if cl := cs.customOf(r); cl != nil {
+			j := runEnd(s, i, cl.alphabet.Contains)
+			ct, err := c.classRun(cl, s[i:j], opts, encrypt)
 			if err != nil {
 				return "", err
 			}
-			out.WriteString(pt)
-			i = j
-			continue
-		}
-
-		if isDigit(b) {
-			j := i
-			for j < len(s) && isDigit(s[j]) {
-				j++
-			}
-			pt, err := c.decryptDigitsNoLeadingZero(s[i:j])
-			if err != nil {
-				return "", err
-			}
-			out.WriteString(pt)
+			out.WriteString(ct)
 			i = j
 			continue
 		}
 
-		if isUpper(b) {
-			j := i
-			for j < len(s) && isUpper(s[j]) {
-				j++
-			}
-			pt, err := c.decryptLetters(s[i:j], true)
+		// alphanumeric span (segmentation policies other than class runs)
+		if span != nil && span.Contains(r) {
+			j := runEnd(s, i, span.Contains)
+			ct, err := c.span(cs, span, s[i:j], opts, encrypt)
 			if err != nil {
 				return "", err
 			}
-			out.WriteString(pt)
+			out.WriteString(ct)
 			i = j
 			continue
 		}
 
-		if isLower(b) {
-			j := i
-			for j < len(s) && isLower(s[j]) {
-				j++
+		// digit, uppercase or lowercase run
+		if cl := cs.builtinOf(r); cl != nil {
+			if cl.numeric && opts.Grouping {
+				if j := groupEnd(cs, s, i); j > i {
+					ct, err := c.grouped(cl, s[i:j], opts, encrypt)
+					if err != nil {
+						return "", err
+					}
+					out.WriteString(ct)
+					i = j
+					continue
+				}
 			}
-			pt, err := c.decryptLetters(s[i:j], false)
+			j := runEnd(s, i, cl.alphabet.Contains)
+			ct, err := c.classRun(cl, s[i:j], opts, encrypt)
 			if err != nil {
 				return "", err
 			}
-			out.WriteString(pt)
+			out.WriteString(ct)
 			i = j
 			continue
 		}
 
-		out.WriteByte(b)
-		i++
+		// passthrough (including '-' of signed numbers and invalid UTF-8)
+		out.WriteString(s[i : i+size])
+		i += size
 	}
 	return out.String(), nil
 }
 
-// ---- digits: FF1 radix10 + cycle-walking to avoid leading '0' ----
+// classRun encrypts one run of cl's runes as a single domain.
+func (c *fpeCore) classRun(cl *charClass, seg string, opts PreserveOptions, encrypt bool) (string, error) {
+	tweak := classTweak(cl.tweak, opts.Tweak)
+	if cl.numeric {
+		return c.digits(seg, opts.Numeric, tweak, encrypt)
+	}
+	runes := []rune(seg)
+	vals := make([]int, len(runes))
+	radices := make([]int, len(runes))
+	for i, r := range runes {
+		vals[i], radices[i] = cl.alphabet.Index(r), cl.alphabet.Radix()
+	}
+	out, err := c.permuteNumerals(vals, radices, tweak, encrypt)
+	if err != nil {
+		return "", err
+	}
+	for i, v := range out {
+		runes[i] = cl.alphabet.Rune(v)
+	}
+	return string(runes), nil
+}
 
-func (c *FPECipher) encryptDigitsNoLeadingZero(num string) (string, error) {
-	if len(num) == 0 {
-		return num, nil
+// ---- grouped numbers ----
+
+// isGroupSep reports whether r is a thousands separator.
+func isGroupSep(r rune) bool {
+	switch r {
+	case ',', '.', ' ', '\'', '\u00a0', '\u202f', '\u2019':
+		return true
 	}
-	X := num
-	for {
-		ct, err := c.ffDigits.Encrypt(X)
-		if err != nil {
-			return "", err
+	return false
+}
+
+// groupEnd returns the end of the grouped number starting at i: 1-3
+// digits, then one or more groups of exactly 3 digits, all joined by the
+// same separator. It returns i when s[i:] does not start with one. The
+// result depends only on where the digits are and on the separators,
+// which encryption keeps, so decryption finds the same number.
+func groupEnd(cs *classSet, s string, i int) int {
+	j := i
+	for j < len(s) && isDigit(s[j]) {
+		j++
+	}
+	if j-i > 3 {
+		return i
+	}
+	end := i
+	var sep rune
+	for j < len(s) {
+		r, size := utf8.DecodeRuneInString(s[j:])
+		if !isGroupSep(r) || (sep != 0 && r != sep) || cs.customOf(r) != nil {
+			break
+		}
+		k := j + size
+		n := 0
+		for k+n < len(s) && isDigit(s[k+n]) {
+			n++
 		}
-		if ct[0] != '0' { // accept only if first char is not '0'
-			return ct, nil
+		if n != 3 {
+			break
 		}
-		// cycle-walk: re-encrypt the ciphertext until constraint satisfied
-		X = ct
+		sep, j = r, k+3
+		end = j
 	}
+	return end
 }
 
-func (c *FPECipher) decryptDigitsNoLeadingZero(ct string) (string, error) {
-	if len(ct) == 0 {
-		return ct, nil
-	}
-	X := ct
-	for {
-		pt, err := c.ffDigits.Decrypt(X)
-		if err != nil {
-			return "", err
+// grouped encrypts the digits of a grouped number as one run of cl and
+// puts the separators back.
+func (c *fpeCore) grouped(cl *charClass, seg string, opts PreserveOptions, encrypt bool) (string, error) {
+	var digits []byte
+	for i := 0; i < len(seg); i++ {
+		if isDigit(seg[i]) {
+			digits = append(digits, seg[i])
 		}
-		if pt[0] != '0' { // inverse of the same cycle-walk constraint
-			return pt, nil
+	}
+	Y, err := c.classRun(cl, string(digits), opts, encrypt)
+	if err != nil {
+		return "", err
+	}
+	out := []byte(seg)
+	j := 0
+	for i := range out {
+		if isDigit(out[i]) {
+			out[i] = Y[j]
+			j++
 		}
-		X = pt
 	}
+	return string(out), nil
 }
 
-// ---- letters: map to radix26, run FF1, then map back ----
+// ---- CipherV2 ----
 
-func (c *FPECipher) encryptLetters(seg string, upper bool) (string, error) {
-	// map letters -> radix26 digits
-	buf := make([]byte, len(seg))
-	for i := 0; i < len(seg); i++ {
-		var v int
-		if upper {
-			v = int(seg[i] - 'A') // 0..25
-		} else {
-			v = int(seg[i] - 'a') // 0..25
-		}
-		buf[i] = encRadix26(v) // -> '0'..'9','A'..'P'
+// EncryptContext is EncryptPreservingWithTweak with the CipherV2 signature.
+// The tweak is taken from ctx (see WithTweak).
+func (c *fpeCore) EncryptContext(ctx context.Context, s string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
 	}
-	X := string(buf)
+	return c.EncryptPreservingWithTweak(s, TweakFromContext(ctx))
+}
 
-	var ct string
-	var err error
-	if upper {
-		ct, err = c.ffUpper.Encrypt(X) // still a string of radix26 digits
-	} else {
-		ct, err = c.ffLower.Encrypt(X)
+// DecryptContext is DecryptPreservingWithTweak with the CipherV2 signature.
+func (c *fpeCore) DecryptContext(ctx context.Context, s string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
 	}
-	if err != nil {
+	return c.DecryptPreservingWithTweak(s, TweakFromContext(ctx))
+}
+
+// EncryptNumberContext encrypts an optionally signed digit string.
+// Anything else is rejected with ErrNotNumber.
+func (c *fpeCore) EncryptNumberContext(ctx context.Context, s string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if !isNumber(s) {
+		return "", ErrNotNumber
+	}
+	return c.EncryptPreservingWithTweak(s, TweakFromContext(ctx))
+}
+
+// DecryptNumberContext is the inverse of EncryptNumberContext.
+func (c *fpeCore) DecryptNumberContext(ctx context.Context, s string) (string, error) {
+	if err := ctx.Err(); err != nil {
 		return "", err
 	}
+	if !isNumber(s) {
+		return "", ErrNotNumber
+	}
+	return c.DecryptPreservingWithTweak(s, TweakFromContext(ctx))
+}
 
-	// map radix26 digits -> letters
-	for i := 0; i < len(ct); i++ {
-		v, err := decRadix26(ct[i])
+// ---- digits: radix10 + cycle-walking to keep the leading '0' class ----
+
+func (c *fpeCore) digits(num string, mode NumericMode, tweak []byte, encrypt bool) (string, error) {
+	if len(num) == 0 {
+		return num, nil
+	}
+	if mode == NumericFixedLength {
+		return c.run(10, num, tweak, encrypt)
+	}
+	return c.digitsNoLeadingZero(num, tweak, encrypt)
+}
+
+// digitsNoLeadingZero cycle-walks until the output starts with '0' exactly
+// when the input does. The walk never leaves the input's half of the
+// domain, so it permutes each half and decryption walks the same cycle back.
+func (c *fpeCore) digitsNoLeadingZero(num string, tweak []byte, encrypt bool) (string, error) {
+	zero := num[0] == '0'
+	X := num
+	for {
+		Y, err := c.run(10, X, tweak, encrypt)
 		if err != nil {
 			return "", err
 		}
-		if upper {
-			buf[i] = byte('A' + v)
-		} else {
-			buf[i] = byte('a' + v)
+		if (Y[0] == '0') == zero { // accept only if the leading '0' class matches
+			return Y, nil
 		}
+		// cycle-walk: re-apply to the output until constraint satisfied
+		X = Y
 	}
-	return string(buf), nil
 }
 
-func (c *FPECipher) decryptLetters(seg string, upper bool) (string, error) {
-	// map letters -> radix26 digits
-	buf := make([]byte, len(seg))
-	for i := 0; i < len(seg); i++ {
-		if upper {
-			buf[i] = encRadix26(int(seg[i] - 'A'))
-		} else {
-			buf[i] = encRadix26(int(seg[i] - 'a'))
+// ---- alphanumeric spans: one domain per span ----
+
+func (c *fpeCore) span(cs *classSet, alphabet *Alphabet, seg string, opts PreserveOptions, encrypt bool) (string, error) {
+	tweak := classTweak(spanTweaks[opts.Segmentation-1][opts.Classes], opts.Tweak)
+
+	runes := []rune(seg)
+	classes := make([]*Alphabet, len(runes))
+	vals := make([]int, len(runes))
+	radices := make([]int, len(runes))
+	for i, r := range runes {
+		a := alphabet
+		if opts.Classes == PreserveClasses {
+			a = nil
+			if cl := cs.builtinOf(r); cl != nil {
+				a = cl.alphabet
+			}
+		}
+		if a == nil { // a lone rune left of a built-in class: kept as-is
+			vals[i], radices[i] = 0, 1
+			continue
 		}
+		classes[i] = a
+		vals[i], radices[i] = a.Index(r), a.Radix()
 	}
-	X := string(buf)
 
-	var pt string
-	var err error
-	if upper {
-		pt, err = c.ffUpper.Decrypt(X)
-	} else {
-		pt, err = c.ffLower.Decrypt(X)
-	}
+	out, err := c.permuteNumerals(vals, radices, tweak, encrypt)
 	if err != nil {
 		return "", err
 	}
-
-	// map radix26 digits -> letters
-	for i := 0; i < len(pt); i++ {
-		v, err := decRadix26(pt[i])
-		if err != nil {
-			return "", err
-		}
-		if upper {
-			buf[i] = byte('A' + v)
-		} else {
-			buf[i] = byte('a' + v)
+	for i, v := range out {
+		if classes[i] != nil {
+			runes[i] = classes[i].Rune(v)
 		}
 	}
-	return string(buf), nil
+	return string(runes), nil
 }
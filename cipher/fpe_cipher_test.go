@@ -0,0 +1,102 @@
+package cipher
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// fpeCores returns the FF1 and FF3-1 cores under an all-zero key.
+func fpeCores(t *testing.T) map[string]*fpeCore {
+	t.Helper()
+	ff1, err := NewFPECipher(make([]byte, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ff31, err := NewFF31Cipher(make([]byte, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]*fpeCore{"FF1": ff1.fpeCore, "FF3-1": ff31.fpeCore}
+}
+
+func TestMaxTweakLen(t *testing.T) {
+	long := strings.Repeat("x", 64)
+	for name, c := range fpeCores(t) {
+		if err := c.RegisterClass(long, MustAlphabet("αβγδεζηθικλμνξοπ")); err != nil {
+			t.Fatal(err)
+		}
+		for _, n := range []int{0, 1, MaxTweakLen - 20, MaxTweakLen} {
+			tweak := make([]byte, n)
+			for _, s := range []string{"1234567890", "ABCDEFGH", "αβγδεζηθ"} {
+				enc, err := c.EncryptPreservingWithTweak(s, tweak)
+				if err != nil {
+					t.Fatalf("%s: %d-byte tweak, EncryptPreservingWithTweak(%q): %v", name, n, s, err)
+				}
+				if dec, err := c.DecryptPreservingWithTweak(enc, tweak); err != nil || dec != s {
+					t.Errorf("%s: DecryptPreservingWithTweak(%q) = %q, %v; want %q", name, enc, dec, err, s)
+				}
+			}
+			// range bounds make the mode tweak arbitrarily long
+			max := new(big.Int).Exp(big.NewInt(10), big.NewInt(45), nil)
+			v := big.NewInt(12345)
+			enc, _, err := c.EncryptRangeBig(v, big.NewInt(0), max, RangeOptions{Tweak: tweak})
+			if err != nil {
+				t.Fatalf("%s: %d-byte tweak, EncryptRangeBig: %v", name, n, err)
+			}
+			if dec, _, err := c.DecryptRangeBig(enc, big.NewInt(0), max, RangeOptions{Tweak: tweak}); err != nil || dec.Cmp(v) != 0 {
+				t.Errorf("%s: DecryptRangeBig = %v, %v; want %v", name, dec, err, v)
+			}
+		}
+		if _, err := c.EncryptPreservingWithTweak("1234567890", make([]byte, MaxTweakLen+1)); !errors.Is(err, ErrTweakTooLong) {
+			t.Errorf("%s: tweak above MaxTweakLen: error = %v, want ErrTweakTooLong", name, err)
+		}
+	}
+}
+
+func TestGrouping(t *testing.T) {
+	grouped := PreserveOptions{Grouping: true}
+	for name, c := range fpeCores(t) {
+		for _, s := range []string{
+			"1,234,567", "1.234.567", "1 234 567", "1'234'567", "12 345",
+			"Total: -987,654,321 USD", "999,999 and 1,000", "100,000,000,000,000",
+		} {
+			enc, err := c.EncryptPreservingWith(s, grouped)
+			if err != nil {
+				t.Fatalf("%s: EncryptPreservingWith(%q): %v", name, s, err)
+			}
+			// separators stay, so the result is grouped the same way
+			if len(enc) != len(s) {
+				t.Fatalf("%s: EncryptPreservingWith(%q) = %q, length changed", name, s, enc)
+			}
+			for i := 0; i < len(s); i++ {
+				if isDigit(s[i]) != isDigit(enc[i]) || isGroupSep(rune(s[i])) && s[i] != enc[i] {
+					t.Errorf("%s: EncryptPreservingWith(%q) = %q, not grouped the same way", name, s, enc)
+					break
+				}
+			}
+			if dec, err := c.DecryptPreservingWith(enc, grouped); err != nil || dec != s {
+				t.Errorf("%s: DecryptPreservingWith(%q) = %q, %v; want %q", name, enc, dec, err, s)
+			}
+		}
+
+		// the digits of a grouped number form one domain: they encrypt as
+		// the ungrouped number does
+		enc, _ := c.EncryptPreservingWith("1,234,567", grouped)
+		plain, _ := c.EncryptPreservingWith("1234567", PreserveOptions{})
+		if strings.ReplaceAll(enc, ",", "") != plain {
+			t.Errorf("%s: grouped %q, ungrouped %q", name, enc, plain)
+		}
+		// without Grouping the groups are separate runs
+		if sep, _ := c.EncryptPreservingWith("1,234,567", PreserveOptions{}); sep == enc {
+			t.Errorf("%s: Grouping made no difference for %q", name, "1,234,567")
+		}
+		// "12,34" is not a grouped number and stays two runs
+		a, _ := c.EncryptPreservingWith("12,34", grouped)
+		b, _ := c.EncryptPreservingWith("12,34", PreserveOptions{})
+		if a != b {
+			t.Errorf("%s: %q encrypted as a grouped number: %q, want %q", name, "12,34", a, b)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package cipher
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+// ---------------------------
+// char classes for EncryptPreserving
+// ---------------------------
+
+// charClass is one class of EncryptPreserving: runs of its runes are
+// encrypted as one radix-len(alphabet) domain under its own tweak.
+type charClass struct {
+	name     string
+	alphabet *Alphabet
+	tweak    []byte
+	numeric  bool // the full 0-9 class: NumericMode applies
+}
+
+// classSet partitions runes into classes. Custom classes claim their runes
+// first; the built-in classes and span alphabets keep what is left, so
+// each rune has exactly one class and ciphertext runs end where the
+// plaintext runs did.
+type classSet struct {
+	custom  []*charClass
+	builtin []*charClass // digits, uppercase, lowercase
+	spans   [2]*Alphabet // SegmentAlnum36, SegmentAlnum62
+}
+
+var (
+	alphabetAlnum36 = MustAlphabet("0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	alphabetAlnum62 = MustAlphabet("0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz")
+)
+
+// newClassSet builds the partition for the given custom classes.
+func newClassSet(custom []*charClass) *classSet {
+	claimed := func(r rune) bool {
+		for _, cl := range custom {
+			if cl.alphabet.Contains(r) {
+				return true
+			}
+		}
+		return false
+	}
+	cs := &classSet{custom: custom}
+	for _, b := range []charClass{
+		{name: "digits", alphabet: AlphabetDigits, tweak: tweakD, numeric: true},
+		{name: "upper", alphabet: AlphabetUpper, tweak: tweakU},
+		{name: "lower", alphabet: AlphabetLower, tweak: tweakL},
+	} {
+		a := b.alphabet.without(claimed)
+		if a == nil {
+			continue // fewer than two runes left: copied as-is
+		}
+		b.numeric = b.numeric && a == b.alphabet
+		b.alphabet = a
+		cs.builtin = append(cs.builtin, &b)
+	}
+	cs.spans[0] = alphabetAlnum36.without(claimed)
+	cs.spans[1] = alphabetAlnum62.without(claimed)
+	return cs
+}
+
+// customOf returns the custom class of r, or nil.
+func (cs *classSet) customOf(r rune) *charClass {
+	for _, cl := range cs.custom {
+		if cl.alphabet.Contains(r) {
+			return cl
+		}
+	}
+	return nil
+}
+
+// builtinOf returns the built-in class of r, or nil.
+func (cs *classSet) builtinOf(r rune) *charClass {
+	for _, cl := range cs.builtin {
+		if cl.alphabet.Contains(r) {
+			return cl
+		}
+	}
+	return nil
+}
+
+// runEnd returns the end of the run starting at i whose runes satisfy in.
+func runEnd(s string, i int, in func(rune) bool) int {
+	for i < len(s) {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if !in(r) {
+			break
+		}
+		i += size
+	}
+	return i
+}
+
+// RegisterClass adds a character class to EncryptPreserving: runs of
+// runes from a are encrypted as one radix-a.Radix() domain, with a tweak
+// derived from name. Examples are AlphabetHex, AlphabetCrockford32 or an
+// identifier alphabet containing '_'.
+//
+// A custom class takes its runes away from the built-in digit, uppercase
+// and lowercase classes (and from the alphanumeric spans), which then
+// keep only what is left. Custom classes must not overlap each other.
+// Ciphertexts depend on the registered classes, so register them once,
+// before the cipher is used.
+func (c *fpeCore) RegisterClass(name string, a *Alphabet) error {
+	return c.registerClasses(newCharClass(name, a))
+}
+
+func newCharClass(name string, a *Alphabet) *charClass {
+	return &charClass{name: name, alphabet: a, tweak: DeriveTweak("C", name)}
+}
+
+// registerClasses checks add against the registered classes and each
+// other, then stores them all in one swap, so a failed call registers
+// nothing.
+func (c *fpeCore) registerClasses(add ...*charClass) error {
+	c.classMu.Lock()
+	defer c.classMu.Unlock()
+	old := c.classes.Load()
+	custom := old.custom[:len(old.custom):len(old.custom)]
+	for _, cl := range add {
+		if cl.name == "" || cl.alphabet == nil {
+			return errors.New("class needs a name and an alphabet")
+		}
+		for _, prev := range custom {
+			if prev.name == cl.name {
+				return fmt.Errorf("class %q already registered", cl.name)
+			}
+			for _, r := range cl.alphabet.runes {
+				if prev.alphabet.Contains(r) {
+					return fmt.Errorf("class %q overlaps class %q at %q", cl.name, prev.name, r)
+				}
+			}
+		}
+		custom = append(custom, cl)
+	}
+	c.classes.Store(newClassSet(custom))
+	return nil
+}
+
+// scripts maps the names accepted by RegisterScript to their alphabets.
+var scripts = map[string][2]*Alphabet{
+	"vietnamese": {AlphabetVietnameseLower, AlphabetVietnameseUpper},
+	"latin1":     {AlphabetLatin1Lower, AlphabetLatin1Upper},
+	"cyrillic":   {AlphabetCyrillicLower, AlphabetCyrillicUpper},
+	"greek":      {AlphabetGreekLower, AlphabetGreekUpper},
+}
+
+// RegisterScript registers the lowercase and uppercase alphabets of a
+// script ("vietnamese", "latin1", "cyrillic" or "greek") as two classes,
+// so letters of that script are encrypted into letters of the same script
+// and case. Text stays valid UTF-8 with the same number of runes. Both
+// classes are registered, or neither.
+func (c *fpeCore) RegisterScript(name string) error {
+	a, ok := scripts[name]
+	if !ok {
+		return fmt.Errorf("unknown script %q", name)
+	}
+	return c.registerClasses(newCharClass(name+"-lower", a[0]), newCharClass(name+"-upper", a[1]))
+}
@@ -0,0 +1,93 @@
+package cipher
+
+import "testing"
+
+// values returns the numeral values of the runes of s in a.
+func values(a *Alphabet, s string) []int {
+	var v []int
+	for _, r := range s {
+		v = append(v, a.Index(r))
+	}
+	return v
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// TestClassTweaksSeparated checks that a caller tweak cannot make one
+// custom class reuse another class's permutation: with "C-"+name tweaks
+// appended plainly, class "a" under tweak "b" equalled class "ab".
+func TestClassTweaksSeparated(t *testing.T) {
+	short := MustAlphabet("!\"#$%&'()*+,-./:")
+	long := MustAlphabet(";<=>?@[\\]^_`{|}~")
+	c, err := NewFPECipher(make([]byte, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RegisterClass("a", short); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RegisterClass("ab", long); err != nil {
+		t.Fatal(err)
+	}
+	x, err := c.EncryptPreservingWithTweak("!!!!", []byte("b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	y, err := c.EncryptPreserving(";;;;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vx, vy := values(short, x), values(long, y); equalInts(vx, vy) {
+		t.Errorf("class a under tweak b and class ab share a permutation: %v", vx)
+	}
+	if back, err := c.DecryptPreservingWithTweak(x, []byte("b")); err != nil || back != "!!!!" {
+		t.Errorf("DecryptPreservingWithTweak(%q) = %q, %v", x, back, err)
+	}
+}
+
+// TestClassTweakNilUnchanged pins the tweak of the built-in classes
+// without a caller tweak, which existing ciphertexts depend on.
+func TestClassTweakNilUnchanged(t *testing.T) {
+	if got := classTweak(tweakD, nil); string(got) != "D-TWEAK" {
+		t.Errorf("classTweak(tweakD, nil) = %q", got)
+	}
+	if got := classTweak(tweakD, []byte{}); string(got) == "D-TWEAK" {
+		t.Errorf("an empty caller tweak must differ from none")
+	}
+}
+
+// TestRegisterScriptAllOrNothing checks that a script whose uppercase
+// alphabet clashes with a registered class leaves no lowercase class
+// behind.
+func TestRegisterScriptAllOrNothing(t *testing.T) {
+	c, err := NewFPECipher(make([]byte, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RegisterClass("zhe", MustAlphabet("ЖЗ")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RegisterScript("cyrillic"); err == nil {
+		t.Fatal("RegisterScript succeeded over an overlapping class")
+	}
+	if custom := c.classes.Load().custom; len(custom) != 1 || custom[0].name != "zhe" {
+		var names []string
+		for _, cl := range custom {
+			names = append(names, cl.name)
+		}
+		t.Errorf("classes after the failed call = %v, want [zhe]", names)
+	}
+	if err := c.RegisterScript("greek"); err != nil {
+		t.Errorf("RegisterScript(greek): %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package cipher
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestDateDefaultLayouts(t *testing.T) {
+	for name, c := range fpeCores(t) {
+		for _, s := range []string{
+			"2024-02-29", "29/02/2024", "31.12.1999", "01-01-1900",
+			"2099/12/31", "20240615",
+		} {
+			enc, err := c.EncryptDate(s, DateOptions{})
+			if err != nil {
+				t.Fatalf("%s: EncryptDate(%q): %v", name, s, err)
+			}
+			if len(enc) != len(s) {
+				t.Errorf("%s: EncryptDate(%q) = %q, layout changed", name, s, enc)
+			}
+			if dec, err := c.DecryptDate(enc, DateOptions{}); err != nil || dec != s {
+				t.Errorf("%s: DecryptDate(%q) = %q, %v; want %q", name, enc, dec, err, s)
+			}
+		}
+		if _, err := c.EncryptDate("2024-02-30", DateOptions{}); !errors.Is(err, ErrInvalidDate) {
+			t.Errorf("%s: 2024-02-30 error = %v, want ErrInvalidDate", name, err)
+		}
+		if _, err := c.EncryptDate("1899-12-31", DateOptions{}); !errors.Is(err, ErrDateRange) {
+			t.Errorf("%s: 1899-12-31 error = %v, want ErrDateRange", name, err)
+		}
+	}
+}
+
+// TestDateAmbiguousLayouts lists both month-first and day-first layouts,
+// so every ciphertext with day and month up to 12 is ambiguous and must
+// be walked past.
+func TestDateAmbiguousLayouts(t *testing.T) {
+	opts := DateOptions{
+		Min:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Max:     time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC),
+		Layouts: []string{"01/02/2006", "02/01/2006"},
+	}
+	for name, c := range fpeCores(t) {
+		walked := 0
+		for d := opts.Min; !d.After(opts.Max); d = d.AddDate(0, 0, 1) {
+			if d.Day() <= 12 {
+				// ambiguous input is rejected
+				if _, err := c.EncryptDate(d.Format("01/02/2006"), opts); !errors.Is(err, ErrInvalidDate) {
+					t.Fatalf("%s: ambiguous %s: error = %v, want ErrInvalidDate", name, d.Format("01/02/2006"), err)
+				}
+				continue
+			}
+			for _, layout := range opts.Layouts {
+				s := d.Format(layout)
+				enc, err := c.EncryptDate(s, opts)
+				if err != nil {
+					t.Fatalf("%s: EncryptDate(%q): %v", name, s, err)
+				}
+				e, err := time.Parse(layout, enc)
+				if err != nil || e.Day() <= 12 || e.Before(opts.Min) || e.After(opts.Max) {
+					t.Fatalf("%s: EncryptDate(%q) = %q, ambiguous or out of range", name, s, enc)
+				}
+				if dec, err := c.DecryptDate(enc, opts); err != nil || dec != s {
+					t.Errorf("%s: DecryptDate(%q) = %q, %v; want %q", name, enc, dec, err, s)
+				}
+			}
+			// count inputs whose first candidate was ambiguous
+			v, _, _ := c.permuteInt(big.NewInt(366), big.NewInt(int64(d.YearDay()-1)), tweakDate, true)
+			if opts.Min.AddDate(0, 0, int(v.Int64())).Day() <= 12 {
+				walked++
+			}
+		}
+		if walked == 0 {
+			t.Errorf("%s: no input needed a cycle-walk step", name)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package cipher
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecimal(t *testing.T) {
+	for name, c := range fpeCores(t) {
+		for _, tc := range []struct {
+			s    string
+			opts DecimalOptions
+		}{
+			{"1234.56", DecimalOptions{}},
+			{"-0.05", DecimalOptions{}},
+			{"0.5", DecimalOptions{}},
+			{"7", DecimalOptions{}},
+			{"$1,234,567.89", DecimalOptions{Group: ','}},
+			{"-1.234.567,89 ₫", DecimalOptions{Point: ',', Group: '.', Tweak: []byte("price")}},
+			{"USD 99.999", DecimalOptions{}},
+		} {
+			enc, err := c.EncryptDecimal(tc.s, tc.opts)
+			if err != nil {
+				t.Fatalf("%s: EncryptDecimal(%q): %v", name, tc.s, err)
+			}
+			// sign, scale and separators stay where they are
+			if len(enc) != len(tc.s) {
+				t.Fatalf("%s: EncryptDecimal(%q) = %q, length changed", name, tc.s, enc)
+			}
+			for i := 0; i < len(tc.s); i++ {
+				if isDigit(tc.s[i]) != isDigit(enc[i]) || !isDigit(tc.s[i]) && tc.s[i] != enc[i] {
+					t.Errorf("%s: EncryptDecimal(%q) = %q, sign, scale or separators changed", name, tc.s, enc)
+					break
+				}
+			}
+			// an amount below 1 stays below 1
+			first := strings.IndexFunc(tc.s, isDigitRune)
+			if (tc.s[first] == '0') != (enc[first] == '0') {
+				t.Errorf("%s: EncryptDecimal(%q) = %q, leading zero changed", name, tc.s, enc)
+			}
+			if dec, err := c.DecryptDecimal(enc, tc.opts); err != nil || dec != tc.s {
+				t.Errorf("%s: DecryptDecimal(%q) = %q, %v; want %q", name, enc, dec, err, tc.s)
+			}
+		}
+		for _, bad := range []string{"abc", "1.2.3", "1,234.5", "1..2"} {
+			if _, err := c.EncryptDecimal(bad, DecimalOptions{}); err == nil {
+				t.Errorf("%s: EncryptDecimal(%q) succeeded", name, bad)
+			}
+		}
+	}
+}
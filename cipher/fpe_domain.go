@@ -0,0 +1,226 @@
+package cipher
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ---------------------------
+// FPE core: primitives and domains
+// ---------------------------
+
+// fpeCore implements everything FPECipher and FF31Cipher share: it owns
+// one primitive per radix (built lazily by newFF) and turns runs, mixed-
+// radix positions and integer ranges into calls to those primitives.
+type fpeCore struct {
+	newFF func(radix int) (ffx, error)
+
+	mu sync.Mutex
+	ff map[int]ffx
+
+	// classes is replaced, never modified, by RegisterClass
+	classMu sync.Mutex
+	classes atomic.Pointer[classSet]
+
+	// domains below minDomain use small instead of a primitive
+	minDomain uint64
+	small     *smallPerm
+
+	// prefix-preserving permutation of IP addresses
+	prefix *prefixPerm
+}
+
+func newFPECore(key []byte, label string, minDomain uint64, newFF func(radix int) (ffx, error)) (*fpeCore, error) {
+	if len(key) != 16 && len(key) != 24 && len(key) != 32 {
+		return nil, errors.New("key length must be 16, 24, or 32 bytes")
+	}
+	small, err := newSmallPerm(key, label)
+	if err != nil {
+		return nil, err
+	}
+	prefix, err := newPrefixPerm(key, label)
+	if err != nil {
+		return nil, err
+	}
+	c := &fpeCore{newFF: newFF, ff: make(map[int]ffx), minDomain: minDomain, small: small, prefix: prefix}
+	c.classes.Store(newClassSet(nil))
+	// digits and letters are always needed; fail early on a bad key
+	for _, radix := range []int{10, 26} {
+		if _, err := c.engine(radix); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// engine returns the primitive for radix, building it on first use.
+func (c *fpeCore) engine(radix int) (ffx, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ff, ok := c.ff[radix]; ok {
+		return ff, nil
+	}
+	ff, err := c.newFF(radix)
+	if err != nil {
+		return nil, err
+	}
+	c.ff[radix] = ff
+	return ff, nil
+}
+
+// run encrypts or decrypts X, a string of radix numerals, with the radix
+// primitive or, when radix^len(X) is below its minimum, with c.small.
+func (c *fpeCore) run(radix int, X string, tweak []byte, encrypt bool) (string, error) {
+	n, small := smallDomainSize(radix, len(X), c.minDomain)
+	if !small {
+		ff, err := c.engine(radix)
+		if err != nil {
+			return "", err
+		}
+		if encrypt {
+			return ff.EncryptWithTweak(X, tweak)
+		}
+		return ff.DecryptWithTweak(X, tweak)
+	}
+	var v uint64
+	for i := 0; i < len(X); i++ {
+		d := numeralValue(X[i])
+		if d >= radix {
+			return "", errors.New("string is not within base/radix")
+		}
+		v = v*uint64(radix) + uint64(d)
+	}
+	v = c.small.permute(v, n, tweak, encrypt)
+	Y := make([]byte, len(X))
+	for i := len(Y) - 1; i >= 0; i-- {
+		Y[i] = numerals[v%uint64(radix)]
+		v /= uint64(radix)
+	}
+	return string(Y), nil
+}
+
+// maxCycleWalks bounds every cycle-walk over a binary enclosing domain.
+// Each step succeeds with probability > 1/2, so the bound is never hit
+// in practice; it only turns a bug into an error instead of a hang.
+const maxCycleWalks = 256
+
+// ErrCycleWalkLimit is returned when a cycle-walk exceeds maxCycleWalks.
+var ErrCycleWalkLimit = errors.New("cycle-walk limit exceeded")
+
+// permuteInt encrypts or decrypts v in [0, n) as one domain. Domains below
+// the primitive's minimum use c.small; larger ones run the radix-2
+// primitive over the smallest enclosing power of two and cycle-walk back
+// into [0, n). It also returns the number of extra walks taken.
+func (c *fpeCore) permuteInt(n, v *big.Int, tweak []byte, encrypt bool) (*big.Int, int, error) {
+	if n.IsUint64() && n.Uint64() < c.minDomain {
+		x := c.small.permute(v.Uint64(), n.Uint64(), tweak, encrypt)
+		return new(big.Int).SetUint64(x), 0, nil
+	}
+	bits := new(big.Int).Sub(n, big.NewInt(1)).BitLen()
+	X := v.Text(2)
+	y := new(big.Int)
+	for walks := 0; walks <= maxCycleWalks; walks++ {
+		Y, err := c.run(2, strings.Repeat("0", bits-len(X))+X, tweak, encrypt)
+		if err != nil {
+			return nil, 0, err
+		}
+		y.SetString(Y, 2)
+		if y.Cmp(n) < 0 {
+			return y, walks, nil
+		}
+		X = Y
+	}
+	return nil, 0, ErrCycleWalkLimit
+}
+
+// permuteNumerals encrypts or decrypts vals, where vals[i] is a digit in
+// radix radices[i], as one domain. A uniform radix the primitive supports
+// goes straight through it; anything else is packed into one integer.
+func (c *fpeCore) permuteNumerals(vals, radices []int, tweak []byte, encrypt bool) ([]int, error) {
+	if len(vals) == 0 {
+		return vals, nil
+	}
+	r, uniform := uniformRadix(radices)
+	if uniform && r < 2 {
+		return vals, nil
+	}
+	if uniform && r <= len(numerals) {
+		buf := make([]byte, len(vals))
+		for i, v := range vals {
+			buf[i] = numerals[v]
+		}
+		Y, err := c.run(r, string(buf), tweak, encrypt)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]int, len(Y))
+		for i := 0; i < len(Y); i++ {
+			out[i] = numeralValue(Y[i])
+		}
+		return out, nil
+	}
+	y, _, err := c.permuteInt(mixedDomain(radices), packMixed(vals, radices), tweak, encrypt)
+	if err != nil {
+		return nil, err
+	}
+	return unpackMixed(y, radices), nil
+}
+
+// ---- numerals and mixed radix ----
+
+// numeralValue is the inverse of numerals[v]. Lowercase is accepted for
+// every radix <= 36 because that is what the libraries emit there.
+func numeralValue(ch byte) int {
+	switch {
+	case '0' <= ch && ch <= '9':
+		return int(ch - '0')
+	case 'a' <= ch && ch <= 'z':
+		return 10 + int(ch-'a')
+	default:
+		return 36 + int(ch-'A')
+	}
+}
+
+func uniformRadix(radices []int) (int, bool) {
+	for _, r := range radices[1:] {
+		if r != radices[0] {
+			return 0, false
+		}
+	}
+	return radices[0], true
+}
+
+// mixedDomain returns the product of radices.
+func mixedDomain(radices []int) *big.Int {
+	n := big.NewInt(1)
+	for _, r := range radices {
+		n.Mul(n, big.NewInt(int64(r)))
+	}
+	return n
+}
+
+// packMixed reads vals as a mixed-radix number, most significant first.
+func packMixed(vals, radices []int) *big.Int {
+	v := new(big.Int)
+	for i, d := range vals {
+		v.Mul(v, big.NewInt(int64(radices[i])))
+		v.Add(v, big.NewInt(int64(d)))
+	}
+	return v
+}
+
+// unpackMixed is the inverse of packMixed.
+func unpackMixed(v *big.Int, radices []int) []int {
+	v = new(big.Int).Set(v)
+	out := make([]int, len(radices))
+	r, d := new(big.Int), new(big.Int)
+	for i := len(radices) - 1; i >= 0; i-- {
+		r.SetInt64(int64(radices[i]))
+		v.QuoRem(v, r, d)
+		out[i] = int(d.Int64())
+	}
+	return out
+}
@@ -0,0 +1,228 @@
+package cipher
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"strings"
+)
+
+// ---------------------------
+// email addresses
+// ---------------------------
+
+// EmailDomainPolicy says what EncryptEmail does with the domain.
+type EmailDomainPolicy int
+
+const (
+	// EmailKeepDomain keeps the domain as it is.
+	EmailKeepDomain EmailDomainPolicy = iota
+
+	// EmailEncryptDomain encrypts every label but the last (the TLD) over
+	// [0-9a-z], keeping hyphens and label lengths, so "mail.example.com"
+	// gives something like "q3x7.b0krm2w.com". Domains are
+	// case-insensitive and come back lowercase.
+	EmailEncryptDomain
+
+	// EmailFakeDomain replaces the domain with one of
+	// EmailOptions.FakeDomains, chosen by a keyed hash of the domain. This
+	// is one-way: DecryptEmail restores the local part and keeps the fake
+	// domain.
+	EmailFakeDomain
+)
+
+// EmailOptions are settings for EncryptEmail.
+type EmailOptions struct {
+	// Domain selects the domain policy; the default keeps it.
+	Domain EmailDomainPolicy
+
+	// FakeDomains is the list EmailFakeDomain picks from.
+	FakeDomains []string
+
+	// LocalAlphabet is the alphabet of the local part; nil means
+	// AlphabetAtext. A smaller one, e.g. letters, digits and '_', gives
+	// more ordinary-looking addresses but rejects local parts using other
+	// characters.
+	LocalAlphabet *Alphabet
+
+	// Tweak applies to the local part and, with EmailEncryptDomain, to
+	// every label; see MaxTweakLen.
+	Tweak []byte
+}
+
+// ErrInvalidEmail is returned for input that is not an unquoted
+// dot-atom address with a hostname domain.
+var ErrInvalidEmail = errors.New("not a supported email address")
+
+var (
+	tweakEmailLocal  = []byte("E-LOCAL")
+	tweakEmailDomain = []byte("E-LABEL")
+	tweakEmailFake   = []byte("E-FAKE")
+	alphabetLabel    = MustAlphabet("0123456789abcdefghijklmnopqrstuvwxyz")
+)
+
+// EncryptEmail encrypts an address such as "john.doe@example.com". The
+// local part is encrypted as one domain over the RFC 5322 atext alphabet
+// (or EmailOptions.LocalAlphabet) with its dots kept in place, so the
+// result is again a dot-atom of the same length; the domain is handled by
+// EmailOptions.Domain. Quoted local parts and address literals are not
+// supported (ErrInvalidEmail).
+//
+// FF3-1 encrypts fewer than 192 bits at once, so it takes local parts of
+// at most 30 atext characters, dots aside, and with EmailEncryptDomain
+// labels of at most 36 letters and digits. RFC 5321 allows 64 and 63;
+// such addresses need FF1.
+func (c *fpeCore) EncryptEmail(s string, opts EmailOptions) (string, error) {
+	return c.email(s, opts, true)
+}
+
+// DecryptEmail is the inverse of EncryptEmail with the same options.
+func (c *fpeCore) DecryptEmail(s string, opts EmailOptions) (string, error) {
+	return c.email(s, opts, false)
+}
+
+func (c *fpeCore) email(s string, opts EmailOptions, encrypt bool) (string, error) {
+	if len(opts.Tweak) > MaxTweakLen {
+		return "", ErrTweakTooLong
+	}
+	a := opts.LocalAlphabet
+	if a == nil {
+		a = AlphabetAtext
+	}
+	at := strings.LastIndexByte(s, '@')
+	if at < 0 {
+		return "", ErrInvalidEmail
+	}
+	local, domain := s[:at], s[at+1:]
+	labels := strings.Split(domain, ".")
+	if !validDotAtom(local, a) || !validHostname(labels) {
+		return "", ErrInvalidEmail
+	}
+
+	// local part: one domain, dots in place
+	var vals, radices []int
+	for _, r := range local {
+		if r != '.' {
+			vals = append(vals, a.Index(r))
+			radices = append(radices, a.Radix())
+		}
+	}
+	vals, err := c.permuteNumerals(vals, radices, classTweak(tweakEmailLocal, opts.Tweak), encrypt)
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	out.Grow(len(s))
+	for _, r := range local {
+		if r == '.' {
+			out.WriteByte('.')
+			continue
+		}
+		out.WriteRune(a.Rune(vals[0]))
+		vals = vals[1:]
+	}
+	out.WriteByte('@')
+
+	switch opts.Domain {
+	case EmailKeepDomain:
+		out.WriteString(domain)
+	case EmailEncryptDomain:
+		if len(labels) < 2 {
+			return "", ErrInvalidEmail
+		}
+		for i, label := range labels {
+			if i > 0 {
+				out.WriteByte('.')
+			}
+			if i == len(labels)-1 {
+				out.WriteString(label) // TLD
+				continue
+			}
+			enc, err := c.label(strings.ToLower(label), opts.Tweak, encrypt)
+			if err != nil {
+				return "", err
+			}
+			out.WriteString(enc)
+		}
+	case EmailFakeDomain:
+		if len(opts.FakeDomains) == 0 {
+			return "", errors.New("EmailFakeDomain needs FakeDomains")
+		}
+		if !encrypt {
+			out.WriteString(domain)
+			break
+		}
+		out.WriteString(opts.FakeDomains[c.pick(strings.ToLower(domain), len(opts.FakeDomains))])
+	default:
+		return "", errors.New("unknown email domain policy")
+	}
+	return out.String(), nil
+}
+
+// label encrypts the alphanumerics of a lowercase hostname label as one
+// domain, keeping its hyphens.
+func (c *fpeCore) label(label string, tweak []byte, encrypt bool) (string, error) {
+	var vals, radices []int
+	for i := 0; i < len(label); i++ {
+		if label[i] != '-' {
+			vals = append(vals, alphabetLabel.Index(rune(label[i])))
+			radices = append(radices, alphabetLabel.Radix())
+		}
+	}
+	vals, err := c.permuteNumerals(vals, radices, classTweak(tweakEmailDomain, tweak), encrypt)
+	if err != nil {
+		return "", err
+	}
+	out := []byte(label)
+	for i := range out {
+		if out[i] != '-' {
+			out[i] = byte(alphabetLabel.Rune(vals[0]))
+			vals = vals[1:]
+		}
+	}
+	return string(out), nil
+}
+
+// pick maps v to an index in [0, n) with a keyed hash.
+func (c *fpeCore) pick(v string, n int) int {
+	sum := sha256.Sum256([]byte(v))
+	x := binary.BigEndian.Uint64(sum[:8]) % uint64(n)
+	return int(c.small.permute(x, uint64(n), tweakEmailFake, true))
+}
+
+// validDotAtom reports whether s is a dot-atom over a of at most 64 bytes.
+func validDotAtom(s string, a *Alphabet) bool {
+	if s == "" || len(s) > 64 {
+		return false
+	}
+	for _, atom := range strings.Split(s, ".") {
+		if atom == "" {
+			return false
+		}
+		for _, r := range atom {
+			if !a.Contains(r) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// validHostname reports whether labels form a hostname: letters, digits
+// and inner hyphens, 1-63 bytes per label, 253 bytes in total.
+func validHostname(labels []string) bool {
+	total := len(labels) - 1
+	for _, l := range labels {
+		if l == "" || len(l) > 63 || l[0] == '-' || l[len(l)-1] == '-' {
+			return false
+		}
+		for i := 0; i < len(l); i++ {
+			ch := l[i]
+			if !isDigit(ch) && !('a' <= ch && ch <= 'z') && !('A' <= ch && ch <= 'Z') && ch != '-' {
+				return false
+			}
+		}
+		total += len(l)
+	}
+	return total <= 253
+}
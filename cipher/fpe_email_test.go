@@ -0,0 +1,85 @@
+package cipher
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEmail(t *testing.T) {
+	fakes := []string{"example.org", "example.net"}
+	for name, c := range fpeCores(t) {
+		for _, s := range []string{
+			"john.doe@example.com", "a@b.co", "x_y+tag@mail.sub-domain.example.co.uk",
+			"o'brien!#$%&*@Example.COM", strings.Repeat("z", 30) + "@x.io",
+		} {
+			at := strings.LastIndexByte(s, '@')
+			for _, opts := range []EmailOptions{
+				{},
+				{Domain: EmailEncryptDomain, Tweak: []byte("users.email")},
+				{Domain: EmailFakeDomain, FakeDomains: fakes},
+			} {
+				enc, err := c.EncryptEmail(s, opts)
+				if err != nil {
+					t.Fatalf("%s: EncryptEmail(%q, %v): %v", name, s, opts.Domain, err)
+				}
+				i := strings.LastIndexByte(enc, '@')
+				if i != at || !validDotAtom(enc[:i], AlphabetAtext) || !validHostname(strings.Split(enc[i+1:], ".")) {
+					t.Errorf("%s: EncryptEmail(%q, %v) = %q, not a dot-atom address", name, s, opts.Domain, enc)
+				}
+				for j := range s[:at] {
+					if (s[j] == '.') != (enc[j] == '.') {
+						t.Errorf("%s: EncryptEmail(%q) = %q, dots moved", name, s, enc)
+						break
+					}
+				}
+				want := s
+				switch opts.Domain {
+				case EmailKeepDomain:
+					if enc[i:] != s[at:] {
+						t.Errorf("%s: EncryptEmail(%q) = %q, domain changed", name, s, enc)
+					}
+				case EmailEncryptDomain:
+					// labels come back lowercase; the TLD is kept as it is
+					tld := strings.LastIndexByte(s, '.')
+					want = s[:at] + strings.ToLower(s[at:tld]) + s[tld:]
+					if len(enc) != len(s) || !strings.HasSuffix(enc, s[tld:]) {
+						t.Errorf("%s: EncryptEmail(%q) = %q, domain shape or TLD changed", name, s, enc)
+					}
+				case EmailFakeDomain:
+					want = s[:at] + enc[i:]
+					if d := enc[i+1:]; d != fakes[0] && d != fakes[1] {
+						t.Errorf("%s: EncryptEmail(%q) = %q, not a fake domain", name, s, enc)
+					}
+				}
+				if dec, err := c.DecryptEmail(enc, opts); err != nil || dec != want {
+					t.Errorf("%s: DecryptEmail(%q) = %q, %v; want %q", name, enc, dec, err, want)
+				}
+			}
+		}
+		for _, bad := range []string{"no-at-sign", "a..b@x.com", ".a@x.com", `"q"@x.com`, "a@-x.com", "a@[127.0.0.1]"} {
+			if _, err := c.EncryptEmail(bad, EmailOptions{}); err == nil {
+				t.Errorf("%s: EncryptEmail(%q) succeeded", name, bad)
+			}
+		}
+	}
+}
+
+func TestEmailFF31Limits(t *testing.T) {
+	cores := fpeCores(t)
+	f3, f1 := cores["FF3-1"], cores["FF1"]
+	opts := EmailOptions{Domain: EmailEncryptDomain}
+	for _, tc := range []struct{ fits, not string }{
+		{strings.Repeat("a", 30) + "@x.com", strings.Repeat("a", 31) + "@x.com"},
+		{"a@" + strings.Repeat("b", 36) + ".com", "a@" + strings.Repeat("b", 37) + ".com"},
+	} {
+		if _, err := f3.EncryptEmail(tc.fits, opts); err != nil {
+			t.Errorf("FF3-1 EncryptEmail(%q): %v", tc.fits, err)
+		}
+		if _, err := f3.EncryptEmail(tc.not, opts); err == nil {
+			t.Errorf("FF3-1 EncryptEmail(%q) succeeded", tc.not)
+		}
+		if _, err := f1.EncryptEmail(tc.not, opts); err != nil {
+			t.Errorf("FF1 EncryptEmail(%q): %v", tc.not, err)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package cipher
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ---------------------------
+// hex tokens and UUIDs
+// ---------------------------
+
+// ErrInvalidHex is returned for input without hex digits or with both
+// upper and lowercase hex letters.
+var ErrInvalidHex = errors.New("not a hex token")
+
+// ErrInvalidUUID is returned for input that is not a UUID in the 8-4-4-4-12
+// or the 32-digit form.
+var ErrInvalidUUID = errors.New("not a UUID")
+
+var (
+	tweakHex  = "HEX"
+	tweakUUID = "UUID"
+)
+
+// UUIDOptions are settings for EncryptUUID.
+type UUIDOptions struct {
+	// KeepVersion keeps the version nibble and the variant bits, so an
+	// RFC 9562 UUID encrypts to a valid UUID of the same version. The
+	// other 122 bits (for the usual variant) are encrypted. Without it
+	// all 128 bits are.
+	KeepVersion bool
+
+	// Tweak is bound next to the kept version and variant; see MaxTweakLen.
+	Tweak []byte
+}
+
+// HexOptions configures EncryptHex.
+type HexOptions struct {
+	// Tweak separates keys or IDs that share a cipher; see MaxTweakLen.
+	Tweak []byte
+}
+
+// EncryptHex encrypts the hex digits of a token such as an API key or a
+// hash as one radix-16 domain, instead of the digit and letter runs
+// EncryptPreserving would see. Every other rune, such as a dash, a colon
+// or a leading "0x", is kept where it is.
+//
+// The letters must be all lowercase or all uppercase (ErrInvalidHex
+// otherwise) and the output has the same case. An uppercase token always
+// encrypts to one with at least one letter, so DecryptHex can tell its
+// case; a token without letters is read as lowercase.
+func (c *fpeCore) EncryptHex(s string, opts HexOptions) (string, error) {
+	return c.hex(s, opts.Tweak, true)
+}
+
+// DecryptHex is the inverse of EncryptHex with the same options.
+func (c *fpeCore) DecryptHex(s string, opts HexOptions) (string, error) {
+	return c.hex(s, opts.Tweak, false)
+}
+
+func (c *fpeCore) hex(s string, tweak []byte, encrypt bool) (string, error) {
+	if len(tweak) > MaxTweakLen {
+		return "", ErrTweakTooLong
+	}
+	prefix := ""
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		prefix, s = s[:2], s[2:]
+	}
+	nib, upper, err := hexNibbles(s)
+	if err != nil {
+		return "", err
+	}
+	t := classTweak(DeriveTweak(tweakHex), tweak)
+	if err := c.permuteNibbles(nib, nil, upper, t, encrypt); err != nil {
+		return "", err
+	}
+	return prefix + hexFormat(s, nib, upper), nil
+}
+
+// EncryptUUID encrypts a UUID such as
+// "f47ac10b-58cc-4372-a567-0e02b2c3d479" as one domain of hex digits. The
+// dashes (if any) and the case are kept, as in EncryptHex; see
+// UUIDOptions for keeping the version and variant.
+func (c *fpeCore) EncryptUUID(s string, opts UUIDOptions) (string, error) {
+	return c.uuid(s, opts, true)
+}
+
+// DecryptUUID is the inverse of EncryptUUID with the same options.
+func (c *fpeCore) DecryptUUID(s string, opts UUIDOptions) (string, error) {
+	return c.uuid(s, opts, false)
+}
+
+func (c *fpeCore) uuid(s string, opts UUIDOptions, encrypt bool) (string, error) {
+	if len(opts.Tweak) > MaxTweakLen {
+		return "", ErrTweakTooLong
+	}
+	if !isUUID(s) {
+		return "", ErrInvalidUUID
+	}
+	nib, upper, err := hexNibbles(s)
+	if err != nil {
+		return "", ErrInvalidUUID
+	}
+	t := DeriveTweak(tweakUUID)
+	var kept []int
+	if opts.KeepVersion {
+		// version: nibble 12; variant: the leading 1, 2 or 3 bits of
+		// nibble 16 (0xxx, 10xx, 110x or 111x)
+		kept = make([]int, len(nib))
+		kept[12] = 4
+		switch v := nib[16]; {
+		case v < 8:
+			kept[16] = 1
+		case v < 12:
+			kept[16] = 2
+		default:
+			kept[16] = 3
+		}
+		variant := nib[16] >> (4 - kept[16])
+		t = DeriveTweak(tweakUUID, strconv.Itoa(nib[12]), strconv.Itoa(kept[16])+":"+strconv.Itoa(variant))
+	}
+	if err := c.permuteNibbles(nib, kept, upper, classTweak(t, opts.Tweak), encrypt); err != nil {
+		return "", err
+	}
+	return hexFormat(s, nib, upper), nil
+}
+
+// isUUID reports whether s is 32 hex digits, bare or grouped 8-4-4-4-12.
+func isUUID(s string) bool {
+	switch len(s) {
+	case 32:
+	case 36:
+		if s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+			return false
+		}
+		s = s[:8] + s[9:13] + s[14:18] + s[19:23] + s[24:]
+	default:
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if hexValue(s[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// hexNibbles returns the values of the hex digits in s and whether its
+// letters are uppercase.
+func hexNibbles(s string) (nib []int, upper bool, err error) {
+	lower := false
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		v := hexValue(ch)
+		if v < 0 {
+			continue
+		}
+		switch {
+		case 'a' <= ch && ch <= 'f':
+			lower = true
+		case 'A' <= ch && ch <= 'F':
+			upper = true
+		}
+		nib = append(nib, v)
+	}
+	if len(nib) == 0 || upper && lower {
+		return nil, false, ErrInvalidHex
+	}
+	return nib, upper, nil
+}
+
+// hexFormat writes nib back into the hex positions of s.
+func hexFormat(s string, nib []int, upper bool) string {
+	digits := "0123456789abcdef"
+	if upper {
+		digits = "0123456789ABCDEF"
+	}
+	out := []byte(s)
+	j := 0
+	for i := range out {
+		if hexValue(out[i]) >= 0 {
+			out[i] = digits[nib[j]]
+			j++
+		}
+	}
+	return string(out)
+}
+
+// permuteNibbles encrypts or decrypts nib in place as one domain, keeping
+// the kept[i] high bits of nibble i (kept may be nil). For an uppercase
+// token it cycle-walks until some nibble is a letter, which keeps the
+// token's case readable from the ciphertext.
+func (c *fpeCore) permuteNibbles(nib, kept []int, upper bool, tweak []byte, encrypt bool) error {
+	var vals, radices []int
+	for walks := 0; walks <= maxCycleWalks; walks++ {
+		vals, radices = vals[:0], radices[:0]
+		for i, v := range nib {
+			k := 0
+			if kept != nil {
+				k = kept[i]
+			}
+			if k < 4 {
+				r := 1 << (4 - k)
+				vals = append(vals, v&(r-1))
+				radices = append(radices, r)
+			}
+		}
+		out, err := c.permuteNumerals(vals, radices, tweak, encrypt)
+		if err != nil {
+			return err
+		}
+		for i := range nib {
+			if kept != nil && kept[i] == 4 {
+				continue
+			}
+			r := radices[0]
+			nib[i] = nib[i]&^(r-1) | out[0]
+			out, radices = out[1:], radices[1:]
+		}
+		if !upper || hasHexLetter(nib) {
+			return nil
+		}
+	}
+	return ErrCycleWalkLimit
+}
+
+func hasHexLetter(nib []int) bool {
+	for _, v := range nib {
+		if v >= 10 {
+			return true
+		}
+	}
+	return false
+}
+
+// hexValue returns the value of a hex digit of either case, or -1.
+func hexValue(ch byte) int {
+	switch {
+	case '0' <= ch && ch <= '9':
+		return int(ch - '0')
+	case 'a' <= ch && ch <= 'f':
+		return int(ch-'a') + 10
+	case 'A' <= ch && ch <= 'F':
+		return int(ch-'A') + 10
+	}
+	return -1
+}
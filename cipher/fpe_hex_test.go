@@ -0,0 +1,87 @@
+package cipher
+
+import (
+	"encoding/hex"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestHex(t *testing.T) {
+	for name, c := range fpeCores(t) {
+		for _, s := range []string{
+			"deadbeef", "DEADBEEF", "0x1a2b3c4d", "00:1a:2b:3c:4d:5e", "1234", "sk-0123456789abcdef",
+		} {
+			enc, err := c.EncryptHex(s, HexOptions{})
+			if err != nil {
+				t.Fatalf("%s: EncryptHex(%q): %v", name, s, err)
+			}
+			if len(enc) != len(s) || enc == s {
+				t.Errorf("%s: EncryptHex(%q) = %q", name, s, enc)
+			}
+			for i := 0; i < len(s); i++ {
+				if (hexValue(s[i]) < 0) != (hexValue(enc[i]) < 0) || strings.ToLower(s) == s && strings.ToLower(enc) != enc {
+					t.Errorf("%s: EncryptHex(%q) = %q, separators or case changed", name, s, enc)
+					break
+				}
+			}
+			if dec, err := c.DecryptHex(enc, HexOptions{}); err != nil || dec != s {
+				t.Errorf("%s: DecryptHex(%q) = %q, %v; want %q", name, enc, dec, err, s)
+			}
+		}
+		if _, err := c.EncryptHex("DeadBeef", HexOptions{}); !errors.Is(err, ErrInvalidHex) {
+			t.Errorf("%s: mixed case: error = %v, want ErrInvalidHex", name, err)
+		}
+	}
+}
+
+func TestUUIDKeepVersion(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for name, c := range fpeCores(t) {
+		for i := 0; i < 200; i++ {
+			b := make([]byte, 16)
+			rng.Read(b)
+			s := hex.EncodeToString(b)
+			s = s[:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+			if i%2 == 0 {
+				s = strings.ToUpper(s)
+			}
+			enc, err := c.EncryptUUID(s, UUIDOptions{KeepVersion: true})
+			if err != nil {
+				t.Fatalf("%s: EncryptUUID(%q): %v", name, s, err)
+			}
+			if !isUUID(enc) || len(enc) != len(s) {
+				t.Fatalf("%s: EncryptUUID(%q) = %q, not a UUID", name, s, enc)
+			}
+			// version nibble, and the variant bits: 0xxx, 10xx, 110x or 111x
+			if enc[14] != s[14] {
+				t.Errorf("%s: EncryptUUID(%q) = %q, version changed", name, s, enc)
+			}
+			v, w := hexValue(s[19]), hexValue(enc[19])
+			bits := 1
+			if v >= 12 {
+				bits = 3
+			} else if v >= 8 {
+				bits = 2
+			}
+			if v>>(4-bits) != w>>(4-bits) {
+				t.Errorf("%s: EncryptUUID(%q) = %q, variant changed", name, s, enc)
+			}
+			if dec, err := c.DecryptUUID(enc, UUIDOptions{KeepVersion: true}); err != nil || dec != s {
+				t.Errorf("%s: DecryptUUID(%q) = %q, %v; want %q", name, enc, dec, err, s)
+			}
+		}
+		s := "f47ac10b58cc4372a5670e02b2c3d479"
+		enc, err := c.EncryptUUID(s, UUIDOptions{Tweak: []byte("ids")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dec, err := c.DecryptUUID(enc, UUIDOptions{Tweak: []byte("ids")}); err != nil || dec != s {
+			t.Errorf("%s: DecryptUUID(%q) = %q, %v; want %q", name, enc, dec, err, s)
+		}
+		if _, err := c.EncryptUUID("f47ac10b-58cc-4372-a567", UUIDOptions{}); !errors.Is(err, ErrInvalidUUID) {
+			t.Errorf("%s: short UUID: error = %v, want ErrInvalidUUID", name, err)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package cipher
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ---------------------------
+// IBAN
+// ---------------------------
+
+// ibanFormat is the BBAN of one country in the registry's notation
+// ("8n10n": 8 digits then 10 digits; a = A-Z, c = A-Z or 0-9) and how many
+// leading BBAN characters (bank and branch code) are kept in clear.
+type ibanFormat struct {
+	bban string
+	keep int
+
+	// checks are the BBAN positions of the national check characters,
+	// which national recomputes from the others. They are neither kept nor
+	// encrypted.
+	checks   []int
+	national func(bban []byte)
+}
+
+// ibanFormats are the supported countries. National checks are
+// recomputed for Belgium, France and Monaco (RIB key), Italy and San
+// Marino (CIN), Portugal (NIB) and Spain (control digits). The other
+// countries listed have no national check over the account, or one that
+// depends on the bank and is not kept: Germany (Prüfziffer methods), the
+// United Kingdom and Ireland (sort-code modulus checking), Austria and the
+// Netherlands. Such accounts encrypt to valid IBANs that the bank's own
+// account check may still reject.
+var ibanFormats = map[string]ibanFormat{
+	"AD": {bban: "4n4n12c", keep: 8},
+	"AE": {bban: "3n16n", keep: 3},
+	"AT": {bban: "5n11n", keep: 5},
+	"BE": {bban: "3n7n2n", keep: 3, checks: []int{10, 11}, national: ibanBENational},
+	"BG": {bban: "4a4n2n8c", keep: 8},
+	"CH": {bban: "5n12c", keep: 5},
+	"CY": {bban: "3n5n16c", keep: 8},
+	"DE": {bban: "8n10n", keep: 8},
+	"ES": {bban: "4n4n1n1n10n", keep: 8, checks: []int{8, 9}, national: ibanESNational},
+	"FR": {bban: "5n5n11c2n", keep: 10, checks: []int{21, 22}, national: ibanFRNational},
+	"GB": {bban: "4a6n8n", keep: 10},
+	"GR": {bban: "3n4n16c", keep: 7},
+	"IE": {bban: "4a6n8n", keep: 10},
+	"IT": {bban: "1a5n5n12c", keep: 11, checks: []int{0}, national: ibanITNational},
+	"LI": {bban: "5n12c", keep: 5},
+	"LT": {bban: "5n11n", keep: 5},
+	"LU": {bban: "3n13c", keep: 3},
+	"LV": {bban: "4a13c", keep: 4},
+	"MC": {bban: "5n5n11c2n", keep: 10, checks: []int{21, 22}, national: ibanFRNational},
+	"MT": {bban: "4a5n18c", keep: 9},
+	"NL": {bban: "4a10n", keep: 4},
+	"PL": {bban: "8n16n", keep: 8},
+	"PT": {bban: "4n4n11n2n", keep: 8, checks: []int{19, 20}, national: ibanPTNational},
+	"RO": {bban: "4a16c", keep: 4},
+	"SA": {bban: "2n18c", keep: 2},
+	"SM": {bban: "1a5n5n12c", keep: 11, checks: []int{0}, national: ibanITNational},
+}
+
+// ErrInvalidIBAN is returned for input that is not a valid IBAN of a
+// supported country.
+var ErrInvalidIBAN = errors.New("not a valid IBAN")
+
+var tweakIBAN = "IBAN"
+
+// IBANOptions configures EncryptIBAN.
+type IBANOptions struct {
+	// Tweak is bound next to the country and the kept bank code; see
+	// MaxTweakLen.
+	Tweak []byte
+}
+
+// EncryptIBAN encrypts the account part of an IBAN such as
+// "DE89 3704 0044 0532 0130 00". The country code and the bank (and
+// branch) code stay in clear; the remaining BBAN characters are encrypted
+// as one domain, each position within its class of the country's BBAN
+// structure (n positions stay digits, a positions letters), so the length
+// and per-country format rules still hold. National check characters are
+// recomputed for the countries listed in ibanFormats, then the two IBAN
+// check digits (ISO 7064 MOD 97-10). Spaces are kept where they are.
+//
+// Supported countries are AD, AE, AT, BE, BG, CH, CY, DE, ES, FR, GB, GR,
+// IE, IT, LI, LT, LU, LV, MC, MT, NL, PL, PT, RO, SA and SM; others fail.
+// Bank-specific account checks (DE, GB, IE, AT, NL) are not kept.
+//
+// Input must be uppercase and pass the IBAN check, and the national check
+// where one is recomputed (ErrInvalidIBAN otherwise). The country and
+// bank code are part of the tweak.
+func (c *fpeCore) EncryptIBAN(s string, opts IBANOptions) (string, error) {
+	return c.iban(s, opts.Tweak, true)
+}
+
+// DecryptIBAN is the inverse of EncryptIBAN with the same options.
+func (c *fpeCore) DecryptIBAN(s string, opts IBANOptions) (string, error) {
+	return c.iban(s, opts.Tweak, false)
+}
+
+func (c *fpeCore) iban(s string, tweak []byte, encrypt bool) (string, error) {
+	if len(tweak) > MaxTweakLen {
+		return "", ErrTweakTooLong
+	}
+	compact := []byte(strings.ReplaceAll(s, " ", ""))
+	if len(compact) < 5 {
+		return "", ErrInvalidIBAN
+	}
+	country := string(compact[:2])
+	f, ok := ibanFormats[country]
+	if !ok {
+		return "", fmt.Errorf("IBAN country %q is not supported", country)
+	}
+	bban := compact[4:]
+	classes, err := ibanClasses(f.bban)
+	if err != nil {
+		return "", err
+	}
+	if len(bban) != len(classes) || !isDigit(compact[2]) || !isDigit(compact[3]) || !ibanValid(compact) {
+		return "", ErrInvalidIBAN
+	}
+	// national check characters are recomputed, not encrypted
+	var pos []int
+	for i, ch := range bban {
+		if classes[i].Index(rune(ch)) < 0 {
+			return "", ErrInvalidIBAN
+		}
+		if i >= f.keep && !slices.Contains(f.checks, i) {
+			pos = append(pos, i)
+		}
+	}
+	if f.national != nil {
+		want := append([]byte(nil), bban...)
+		f.national(want)
+		if string(want) != string(bban) {
+			return "", ErrInvalidIBAN
+		}
+	}
+	vals := make([]int, len(pos))
+	radices := make([]int, len(pos))
+	for k, i := range pos {
+		vals[k], radices[k] = classes[i].Index(rune(bban[i])), classes[i].Radix()
+	}
+
+	clear := make([]byte, 0, f.keep)
+	for i := 0; i < f.keep; i++ {
+		if !slices.Contains(f.checks, i) {
+			clear = append(clear, bban[i])
+		}
+	}
+	t := classTweak(DeriveTweak(tweakIBAN, country, string(clear)), tweak)
+	if vals, err = c.permuteNumerals(vals, radices, t, encrypt); err != nil {
+		return "", err
+	}
+	for k, i := range pos {
+		bban[i] = byte(classes[i].Rune(vals[k]))
+	}
+	if f.national != nil {
+		f.national(bban)
+	}
+	payload, _ := ibanDigits(append(append([]byte(nil), bban...), compact[:2]...))
+	check, err := Mod97.Compute(payload)
+	if err != nil {
+		return "", err
+	}
+	compact[2], compact[3] = check[0], check[1]
+
+	out := []byte(s)
+	j := 0
+	for i := range out {
+		if out[i] != ' ' {
+			out[i] = compact[j]
+			j++
+		}
+	}
+	return string(out), nil
+}
+
+// ibanClasses expands a BBAN structure such as "4a6n8n" into one
+// alphabet per position.
+func ibanClasses(spec string) ([]*Alphabet, error) {
+	var out []*Alphabet
+	for len(spec) > 0 {
+		k := 0
+		for k < len(spec) && isDigit(spec[k]) {
+			k++
+		}
+		n, err := strconv.Atoi(spec[:k])
+		if err != nil || k == len(spec) {
+			return nil, fmt.Errorf("bad BBAN structure %q", spec)
+		}
+		var a *Alphabet
+		switch spec[k] {
+		case 'n':
+			a = AlphabetDigits
+		case 'a':
+			a = AlphabetUpper
+		case 'c':
+			a = alphabetAlnum36
+		default:
+			return nil, fmt.Errorf("bad BBAN structure %q", spec)
+		}
+		for ; n > 0; n-- {
+			out = append(out, a)
+		}
+		spec = spec[k+1:]
+	}
+	return out, nil
+}
+
+// ibanValid reports whether the compact IBAN passes the Mod97 check of
+// BBAN || country || check digits, letters read as 10..35.
+func ibanValid(compact []byte) bool {
+	rearranged := append(append([]byte(nil), compact[4:]...), compact[:4]...)
+	digits, ok := ibanDigits(rearranged)
+	return ok && ValidCheckDigit(Mod97, digits)
+}
+
+// ibanDigits replaces letters by 10..35, as the IBAN check requires.
+func ibanDigits(b []byte) (string, bool) {
+	var sb strings.Builder
+	for _, ch := range b {
+		switch {
+		case isDigit(ch):
+			sb.WriteByte(ch)
+		case 'A' <= ch && ch <= 'Z':
+			sb.WriteString(strconv.Itoa(int(ch-'A') + 10))
+		default:
+			return "", false
+		}
+	}
+	return sb.String(), true
+}
+
+// ibanBENational sets the Belgian check: the first 10 digits mod 97,
+// with 0 written as 97.
+func ibanBENational(bban []byte) {
+	r := mod97(string(bban[:10]))
+	if r == 0 {
+		r = 97
+	}
+	bban[10], bban[11] = byte('0'+r/10), byte('0'+r%10)
+}
+
+// ibanFRNational sets the French (and Monegasque) RIB key over bank,
+// branch and account, account letters read as in the RIB: A and J as 1,
+// B, K and S as 2, and so on.
+func ibanFRNational(bban []byte) {
+	digits := make([]byte, 0, 23)
+	for _, ch := range bban[:21] {
+		if !isDigit(ch) {
+			ch = "12345678912345678923456789"[ch-'A']
+		}
+		digits = append(digits, ch)
+	}
+	key := 97 - mod97(string(append(digits, '0', '0')))
+	bban[21], bban[22] = byte('0'+key/10), byte('0'+key%10)
+}
+
+// ibanESNational sets the two Spanish control digits: the first over
+// "00" || bank || branch, the second over the account number.
+func ibanESNational(bban []byte) {
+	bban[8] = esControl(append([]byte("00"), bban[:8]...))
+	bban[9] = esControl(bban[10:20])
+}
+
+func esControl(digits []byte) byte {
+	weights := [10]int{1, 2, 4, 8, 5, 10, 9, 7, 3, 6}
+	sum := 0
+	for i, d := range digits {
+		sum += int(d-'0') * weights[i]
+	}
+	switch d := 11 - sum%11; d {
+	case 11:
+		return '0'
+	case 10:
+		return '1'
+	default:
+		return byte('0' + d)
+	}
+}
+
+// ibanITNational sets the Italian (and Sammarinese) CIN, the letter in
+// front of ABI, CAB and account: characters in odd positions are mapped
+// through a fixed table, the others taken as their value (A = 0), and the
+// sum mod 26 is the letter.
+func ibanITNational(bban []byte) {
+	odd := [26]int{1, 0, 5, 7, 9, 13, 15, 17, 19, 21, 2, 4, 18, 20, 11, 3, 6, 8, 12, 14, 16, 10, 22, 25, 24, 23}
+	sum := 0
+	for i, ch := range bban[1:] {
+		v := int(ch - 'A')
+		if isDigit(ch) {
+			v = int(ch - '0')
+		}
+		if i%2 == 0 {
+			v = odd[v]
+		}
+		sum += v
+	}
+	bban[0] = byte('A' + sum%26)
+}
+
+// ibanPTNational sets the Portuguese NIB check, ISO 7064 MOD 97-10 over
+// the first 19 digits.
+func ibanPTNational(bban []byte) {
+	check, _ := Mod97.Compute(string(bban[:19]))
+	bban[19], bban[20] = check[0], check[1]
+}
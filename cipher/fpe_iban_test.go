@@ -0,0 +1,56 @@
+package cipher
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIBAN(t *testing.T) {
+	for name, c := range fpeCores(t) {
+		for _, s := range []string{
+			"DE89 3704 0044 0532 0130 00", "GB29NWBK60161331926819", "BE68539007547034",
+			"NL91ABNA0417164300", "CH9300762011623852957", "PL61109010140000071219812874",
+			"GR1601101250000000012300695", "LI21088100002324013AA",
+			"FR7630006000011234567890189", "MC5811222000010123456789030",
+			"ES9121000418450200051332", "IT60X0542811101000000123456",
+			"SM86U0322509800000000270100", "PT50000201231234567890154",
+			"AD1200012030200359100100", "BG80BNBG96611020345678", "CY17002001280000001200527600",
+			"LT121000011101001000", "LV80BANK0000435195001", "MT84MALT011000012345MTLCAST001S",
+			"RO49AAAA1B31007593840000",
+		} {
+			e, err := c.EncryptIBAN(s, IBANOptions{})
+			if err != nil {
+				t.Fatalf("%s: EncryptIBAN(%q): %v", name, s, err)
+			}
+			compact := []byte(strings.ReplaceAll(e, " ", ""))
+			if len(e) != len(s) || !ibanValid(compact) {
+				t.Errorf("%s: EncryptIBAN(%q) = %q, not a valid IBAN", name, s, e)
+			}
+			if f := ibanFormats[s[:2]]; f.national != nil {
+				want := append([]byte(nil), compact[4:]...)
+				f.national(want)
+				if string(want) != string(compact[4:]) {
+					t.Errorf("%s: EncryptIBAN(%q) = %q, national check not recomputed", name, s, e)
+				}
+			}
+			if e[:2] != s[:2] {
+				t.Errorf("%s: EncryptIBAN(%q) = %q, country changed", name, s, e)
+			}
+			if d, err := c.DecryptIBAN(e, IBANOptions{}); err != nil || d != s {
+				t.Errorf("%s: DecryptIBAN(%q) = %q, %v; want %q", name, e, d, err, s)
+			}
+		}
+		for _, bad := range []string{
+			"DE89370400440532013001",      // wrong check digits
+			"BE71539007547035",            // wrong Belgian national check
+			"FR8420041010050500013M02607", // wrong RIB key
+			"ES2921000418460200051332",    // wrong Spanish control digits
+			"IT40S0542811101000000123456", // wrong CIN
+			"NO9386011117947",             // not supported
+		} {
+			if _, err := c.EncryptIBAN(bad, IBANOptions{}); err == nil {
+				t.Errorf("%s: EncryptIBAN(%q) succeeded", name, bad)
+			}
+		}
+	}
+}
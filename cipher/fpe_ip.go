@@ -0,0 +1,123 @@
+package cipher
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"net/netip"
+)
+
+// ---------------------------
+// IP addresses (prefix-preserving)
+// ---------------------------
+
+// prefixPerm is the Crypto-PAn permutation (Xu, Fan, Ammar, Moon, 2002):
+// bit i of the output is bit i of the input XOR a keyed bit of the input's
+// first i bits. Two addresses that share an n-bit prefix therefore share
+// an n-bit prefix after encryption, and every address maps to a distinct
+// address of the same family.
+type prefixPerm struct {
+	block cipher.Block
+	pad   [16]byte
+}
+
+// newPrefixPerm derives its AES key and pad from key and label, the same
+// way newSmallPerm does, so it is independent of the FF1/FF3-1 instances.
+func newPrefixPerm(key []byte, label string) (*prefixPerm, error) {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("prefix-preserving/" + label))
+	sum := mac.Sum(nil)
+	block, err := aes.NewCipher(sum[:len(key)])
+	if err != nil {
+		return nil, err
+	}
+	p := &prefixPerm{block: block}
+	mac.Reset()
+	mac.Write([]byte("prefix-preserving pad/" + label))
+	block.Encrypt(p.pad[:], mac.Sum(nil)[:16])
+	return p, nil
+}
+
+// permute encrypts or decrypts the address bytes a in place.
+func (p *prefixPerm) permute(a []byte, encrypt bool) {
+	orig := append([]byte(nil), a...) // plaintext prefix, known bit by bit
+	var in, out [16]byte
+	for i := 0; i < len(a)*8; i++ {
+		// in = first i plaintext bits || pad bits from i on
+		in = p.pad
+		for b := 0; b < i/8; b++ {
+			in[b] = orig[b]
+		}
+		if r := i % 8; r > 0 {
+			mask := byte(0xFF) << (8 - r)
+			in[i/8] = orig[i/8]&mask | p.pad[i/8]&^mask
+		}
+		p.block.Encrypt(out[:], in[:])
+		flip := out[0] >> 7 << (7 - i%8)
+		a[i/8] ^= flip
+		if !encrypt {
+			orig[i/8] = orig[i/8]&^(1<<(7-i%8)) | a[i/8]&(1<<(7-i%8))
+		}
+	}
+}
+
+// EncryptIPAddr anonymizes an IPv4 or IPv6 address with prefix-preserving
+// encryption: addresses sharing an n-bit prefix (the same /24, the same
+// /48) still share an n-bit prefix afterwards, so subnets stay grouped in
+// logs. The family and the zone are kept. An IPv4-mapped IPv6 address
+// stays mapped and its IPv4 part is encrypted as an IPv4 address, so it
+// shares prefixes with the plain IPv4 addresses. It is keyed by the
+// cipher's key; there is no tweak. Structure such as 127.0.0.0/8 or the
+// private ranges is not kept: the whole address is permuted.
+func (c *fpeCore) EncryptIPAddr(ip netip.Addr) (netip.Addr, error) {
+	return c.ipAddr(ip, true)
+}
+
+// DecryptIPAddr is the inverse of EncryptIPAddr.
+func (c *fpeCore) DecryptIPAddr(ip netip.Addr) (netip.Addr, error) {
+	return c.ipAddr(ip, false)
+}
+
+// EncryptIP is EncryptIPAddr on the textual form of an address. The result
+// is in canonical form (RFC 5952 for IPv6).
+func (c *fpeCore) EncryptIP(s string) (string, error) {
+	return c.ipString(s, true)
+}
+
+// DecryptIP is the inverse of EncryptIP.
+func (c *fpeCore) DecryptIP(s string) (string, error) {
+	return c.ipString(s, false)
+}
+
+func (c *fpeCore) ipString(s string, encrypt bool) (string, error) {
+	ip, err := netip.ParseAddr(s)
+	if err != nil {
+		return "", err
+	}
+	ip, err = c.ipAddr(ip, encrypt)
+	if err != nil {
+		return "", err
+	}
+	return ip.String(), nil
+}
+
+func (c *fpeCore) ipAddr(ip netip.Addr, encrypt bool) (netip.Addr, error) {
+	switch {
+	case !ip.IsValid():
+		return netip.Addr{}, errors.New("invalid IP address")
+	case ip.Is4():
+		a := ip.As4()
+		c.prefix.permute(a[:], encrypt)
+		return netip.AddrFrom4(a), nil
+	case ip.Is4In6():
+		a := ip.Unmap().As4()
+		c.prefix.permute(a[:], encrypt)
+		return netip.AddrFrom16(netip.AddrFrom4(a).As16()).WithZone(ip.Zone()), nil
+	default:
+		a := ip.As16()
+		c.prefix.permute(a[:], encrypt)
+		return netip.AddrFrom16(a).WithZone(ip.Zone()), nil
+	}
+}
@@ -0,0 +1,118 @@
+package cipher
+
+import (
+	"math/bits"
+	"math/rand"
+	"net/netip"
+	"testing"
+)
+
+// commonPrefix is the number of leading bits a and b share.
+func commonPrefix(a, b []byte) int {
+	for i := range a {
+		if x := a[i] ^ b[i]; x != 0 {
+			return i*8 + bits.LeadingZeros8(x)
+		}
+	}
+	return len(a) * 8
+}
+
+// withPrefix returns a random address sharing exactly n leading bits
+// with a.
+func withPrefix(rng *rand.Rand, a []byte, n int) []byte {
+	b := make([]byte, len(a))
+	rng.Read(b)
+	for i := 0; i < n; i++ {
+		m := byte(1) << (7 - i%8)
+		b[i/8] = b[i/8]&^m | a[i/8]&m
+	}
+	if n < len(a)*8 {
+		m := byte(1) << (7 - n%8)
+		b[n/8] = b[n/8]&^m | ^a[n/8]&m
+	}
+	return b
+}
+
+func TestIPPrefixPreserving(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for name, c := range fpeCores(t) {
+		for _, size := range []int{4, 16} {
+			for k := 0; k < 20; k++ {
+				a := make([]byte, size)
+				rng.Read(a)
+				for n := 0; n <= size*8; n++ {
+					b := withPrefix(rng, a, n)
+					ip1, _ := netip.AddrFromSlice(a)
+					ip2, _ := netip.AddrFromSlice(b)
+					if size == 16 && (ip1.Is4In6() || ip2.Is4In6()) {
+						continue // mapped addresses are checked below
+					}
+					e1, err := c.EncryptIPAddr(ip1)
+					if err != nil {
+						t.Fatal(err)
+					}
+					e2, _ := c.EncryptIPAddr(ip2)
+					if d, err := c.DecryptIPAddr(e2); err != nil || d != ip2 {
+						t.Fatalf("%s: DecryptIPAddr(%s) = %s, %v; want %s", name, e2, d, err, ip2)
+					}
+					if got := commonPrefix(e1.AsSlice(), e2.AsSlice()); got != n {
+						t.Fatalf("%s: %s and %s share %d bits, encrypted %s and %s share %d", name, ip1, ip2, n, e1, e2, got)
+					}
+				}
+			}
+		}
+
+		// an IPv4-mapped address encrypts as its IPv4 part
+		for k := 0; k < 50; k++ {
+			var a [4]byte
+			rng.Read(a[:])
+			b := withPrefix(rng, a[:], k%33)
+			v4, v4b := netip.AddrFrom4(a), netip.AddrFrom4([4]byte(b))
+			m1 := netip.AddrFrom16(v4.As16())
+			m2 := netip.AddrFrom16(v4b.As16())
+			e4, _ := c.EncryptIPAddr(v4)
+			e1, err := c.EncryptIPAddr(m1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			e2, _ := c.EncryptIPAddr(m2)
+			if !e1.Is4In6() || e1.Unmap() != e4 {
+				t.Errorf("%s: EncryptIPAddr(%s) = %s, want %s mapped", name, m1, e1, e4)
+			}
+			if got := commonPrefix(e1.AsSlice(), e2.AsSlice()); got != 96+k%33 {
+				t.Errorf("%s: %s and %s share %d bits, encrypted share %d", name, m1, m2, 96+k%33, got)
+			}
+		}
+	}
+}
+
+func TestIPRoundTrip(t *testing.T) {
+	for name, c := range fpeCores(t) {
+		for _, s := range []string{
+			"0.0.0.0", "192.168.1.10", "255.255.255.255", "::", "::1",
+			"2001:db8::8a2e:370:7334", "::ffff:10.1.2.3", "fe80::1%eth0",
+		} {
+			e, err := c.EncryptIP(s)
+			if err != nil {
+				t.Fatalf("%s: EncryptIP(%q): %v", name, s, err)
+			}
+			if d, err := c.DecryptIP(e); err != nil || d != s {
+				t.Errorf("%s: DecryptIP(%q) = %q, %v; want %q", name, e, d, err, s)
+			}
+			ip := netip.MustParseAddr(s)
+			ea, err := c.EncryptIPAddr(ip)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ea.String() != e || ea.Zone() != ip.Zone() || ea.Is4() != ip.Is4() {
+				t.Errorf("%s: EncryptIPAddr(%s) = %s, EncryptIP = %s", name, ip, ea, e)
+			}
+			if da, err := c.DecryptIPAddr(ea); err != nil || da != ip {
+				t.Errorf("%s: DecryptIPAddr(%s) = %s, %v; want %s", name, ea, da, err, ip)
+			}
+		}
+		if _, err := c.EncryptIPAddr(netip.Addr{}); err == nil {
+			t.Errorf("%s: EncryptIPAddr of the zero Addr succeeded", name)
+		}
+	}
+}
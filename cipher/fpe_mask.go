@@ -0,0 +1,183 @@
+package cipher
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+// ---------------------------
+// format masks
+// ---------------------------
+
+// Mask is a fixed format such as "DDD-DD-DDDD" or "AA-9999-aa": every rune
+// is either a placeholder, encrypted, or a literal that the value must
+// contain at that position. Placeholders:
+//
+//	D, 9  digit 0-9
+//	A     uppercase A-Z
+//	a     lowercase a-z
+//	X     digit or uppercase [0-9A-Z]
+//	x     digit or lowercase [0-9a-z]
+//	*     digit or letter [0-9A-Za-z]
+//	\     makes the next rune a literal, e.g. "\D" for a literal 'D'
+//
+// Every other rune is a literal, so "+84 DDD DDD DDD" keeps "+84" and the
+// spaces and encrypts the nine digits.
+type Mask struct {
+	pattern string
+	slots   []maskSlot
+	tweak   []byte
+}
+
+// maskSlot is one position: a placeholder alphabet, or a literal rune
+// when alphabet is nil.
+type maskSlot struct {
+	alphabet *Alphabet
+	literal  rune
+}
+
+var maskPlaceholders = map[rune]*Alphabet{
+	'D': AlphabetDigits,
+	'9': AlphabetDigits,
+	'A': AlphabetUpper,
+	'a': AlphabetLower,
+	'X': alphabetAlnum36,
+	'x': MustAlphabet("0123456789abcdefghijklmnopqrstuvwxyz"),
+	'*': alphabetAlnum62,
+}
+
+// ErrMaskMismatch is returned when a value does not fit its mask.
+var ErrMaskMismatch = errors.New("value does not match mask")
+
+// ParseMask parses a mask pattern.
+func ParseMask(pattern string) (*Mask, error) {
+	if !utf8.ValidString(pattern) {
+		return nil, errors.New("mask is not valid UTF-8")
+	}
+	m := &Mask{pattern: pattern}
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r == '\\' {
+			if i+1 == len(runes) {
+				return nil, errors.New("mask ends with an unfinished escape")
+			}
+			i++
+			m.slots = append(m.slots, maskSlot{literal: runes[i]})
+			continue
+		}
+		if a, ok := maskPlaceholders[r]; ok {
+			m.slots = append(m.slots, maskSlot{alphabet: a})
+		} else {
+			m.slots = append(m.slots, maskSlot{literal: r})
+		}
+	}
+	// a 7-byte tweak per mask, so equal slots under two masks differ
+	sum := sha256.Sum256([]byte(pattern))
+	m.tweak = append([]byte("M-"), sum[:5]...)
+	return m, nil
+}
+
+// MustMask is ParseMask that panics on error, for package-level vars.
+func MustMask(pattern string) *Mask {
+	m, err := ParseMask(pattern)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// String returns the pattern m was parsed from.
+func (m *Mask) String() string { return m.pattern }
+
+// Match reports whether s fits m: the same number of runes, every literal
+// in place and every placeholder filled from its alphabet. The error wraps
+// ErrMaskMismatch and names the first bad position.
+func (m *Mask) Match(s string) error {
+	_, err := m.values(s)
+	return err
+}
+
+// values checks s against m and returns the numeral values of its
+// placeholder positions.
+func (m *Mask) values(s string) ([]int, error) {
+	if !utf8.ValidString(s) {
+		return nil, fmt.Errorf("%w: invalid UTF-8", ErrMaskMismatch)
+	}
+	runes := []rune(s)
+	if len(runes) != len(m.slots) {
+		return nil, fmt.Errorf("%w: %d runes, mask %q has %d", ErrMaskMismatch, len(runes), m.pattern, len(m.slots))
+	}
+	var vals []int
+	for i, slot := range m.slots {
+		r := runes[i]
+		if slot.alphabet == nil {
+			if r != slot.literal {
+				return nil, fmt.Errorf("%w: position %d is %q, want %q", ErrMaskMismatch, i, r, slot.literal)
+			}
+			continue
+		}
+		v := slot.alphabet.Index(r)
+		if v < 0 {
+			return nil, fmt.Errorf("%w: position %d is %q, want one of %q", ErrMaskMismatch, i, r, slot.alphabet.String())
+		}
+		vals = append(vals, v)
+	}
+	return vals, nil
+}
+
+// MaskOptions configures EncryptMask.
+type MaskOptions struct {
+	// Tweak is bound next to the mask, which already separates formats;
+	// see MaxTweakLen.
+	Tweak []byte
+}
+
+// EncryptMask encrypts the placeholder positions of s as a single domain
+// (the product of their alphabet sizes) and keeps the literals. s must
+// match m; a malformed value is an ErrMaskMismatch error, not a malformed
+// ciphertext.
+func (c *fpeCore) EncryptMask(s string, m *Mask, opts MaskOptions) (string, error) {
+	return c.mask(s, m, opts.Tweak, true)
+}
+
+// DecryptMask is the inverse of EncryptMask with the same options. The
+// ciphertext is checked against m the same way.
+func (c *fpeCore) DecryptMask(s string, m *Mask, opts MaskOptions) (string, error) {
+	return c.mask(s, m, opts.Tweak, false)
+}
+
+func (c *fpeCore) mask(s string, m *Mask, tweak []byte, encrypt bool) (string, error) {
+	if m == nil {
+		return "", errors.New("nil mask")
+	}
+	if len(tweak) > MaxTweakLen {
+		return "", ErrTweakTooLong
+	}
+	vals, err := m.values(s)
+	if err != nil {
+		return "", err
+	}
+	radices := make([]int, 0, len(vals))
+	for _, slot := range m.slots {
+		if slot.alphabet != nil {
+			radices = append(radices, slot.alphabet.Radix())
+		}
+	}
+	vals, err = c.permuteNumerals(vals, radices, classTweak(m.tweak, tweak), encrypt)
+	if err != nil {
+		return "", err
+	}
+	out := make([]rune, 0, len(m.slots))
+	for _, slot := range m.slots {
+		if slot.alphabet == nil {
+			out = append(out, slot.literal)
+			continue
+		}
+		out = append(out, slot.alphabet.Rune(vals[0]))
+		vals = vals[1:]
+	}
+	return string(out), nil
+}
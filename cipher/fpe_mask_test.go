@@ -0,0 +1,50 @@
+package cipher
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMaskRoundTrip(t *testing.T) {
+	for name, c := range fpeCores(t) {
+		for _, tc := range []struct{ mask, s string }{
+			{"DDD-DD-DDDD", "123-45-6789"},
+			{"AA-9999-aa", "XY-2024-ab"},
+			{"+84 DDD DDD DDD", "+84 912 345 678"},
+			{"XXXX-xxxx-****", "A1B2-c3d4-Zz09"},
+			{`\DDD`, "D42"},
+		} {
+			m := MustMask(tc.mask)
+			for _, opts := range []MaskOptions{{}, {Tweak: []byte("col")}} {
+				enc, err := c.EncryptMask(tc.s, m, opts)
+				if err != nil {
+					t.Fatalf("%s: EncryptMask(%q, %q): %v", name, tc.s, tc.mask, err)
+				}
+				if err := m.Match(enc); err != nil {
+					t.Errorf("%s: EncryptMask(%q, %q) = %q: %v", name, tc.s, tc.mask, enc, err)
+				}
+				if dec, err := c.DecryptMask(enc, m, opts); err != nil || dec != tc.s {
+					t.Errorf("%s: DecryptMask(%q, %q) = %q, %v; want %q", name, enc, tc.mask, dec, err, tc.s)
+				}
+			}
+		}
+	}
+}
+
+func TestMaskMismatch(t *testing.T) {
+	m := MustMask("DDD-DD-DDDD")
+	for name, c := range fpeCores(t) {
+		for _, s := range []string{
+			"123 45 6789", // literal
+			"123-45-678A", // placeholder
+			"123-45-678",  // length
+		} {
+			if _, err := c.EncryptMask(s, m, MaskOptions{}); !errors.Is(err, ErrMaskMismatch) {
+				t.Errorf("%s: EncryptMask(%q) error = %v, want ErrMaskMismatch", name, s, err)
+			}
+			if _, err := c.DecryptMask(s, m, MaskOptions{}); !errors.Is(err, ErrMaskMismatch) {
+				t.Errorf("%s: DecryptMask(%q) error = %v, want ErrMaskMismatch", name, s, err)
+			}
+		}
+	}
+}
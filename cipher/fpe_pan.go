@@ -0,0 +1,116 @@
+package cipher
+
+import (
+	"errors"
+	"strings"
+)
+
+// ---------------------------
+// card numbers (PAN)
+// ---------------------------
+
+// PANOptions are settings for EncryptPAN.
+type PANOptions struct {
+	// BINLength is the number of leading digits kept in clear: 6 or 8.
+	// 0 means 6.
+	BINLength int
+
+	// Tweak is bound next to the BIN and last four digits; see MaxTweakLen.
+	Tweak []byte
+}
+
+var (
+	// ErrInvalidPAN is returned for input that is not 12-19 digits with
+	// optional space and dash separators.
+	ErrInvalidPAN = errors.New("not a card number")
+
+	// ErrLuhn is returned for card numbers whose check digit is wrong.
+	ErrLuhn = errors.New("card number fails the Luhn check")
+)
+
+const (
+	panMinDigits = 12
+	panMaxDigits = 19
+	panLastClear = 4
+)
+
+var tweakPAN = []byte("PAN")
+
+// EncryptPAN tokenizes a card number: the BIN (first 6 or 8 digits) and
+// the last 4 digits stay in clear, the digits in between are encrypted and
+// the result passes the Luhn check again. Spaces and dashes are kept where
+// they are, so "4111 1111 1111 1111" gives "4111 11xx xxxx 1111".
+//
+// The check digit is the last of the 4 clear digits, so it cannot change;
+// instead the last middle digit is chosen to make the token Luhn-valid and
+// only the middle digits before it are encrypted. The input must itself
+// pass the Luhn check (ErrLuhn otherwise): then that digit is implied by
+// the others and decryption restores it exactly. This needs at least two
+// middle digits, e.g. 16 digits with an 8-digit BIN leave 3 encrypted.
+//
+// The clear digits are part of the tweak, so equal middle digits under
+// different BINs or last 4 digits give unrelated tokens.
+func (c *fpeCore) EncryptPAN(s string, opts PANOptions) (string, error) {
+	return c.pan(s, opts, true)
+}
+
+// DecryptPAN is the inverse of EncryptPAN with the same options.
+func (c *fpeCore) DecryptPAN(s string, opts PANOptions) (string, error) {
+	return c.pan(s, opts, false)
+}
+
+func (c *fpeCore) pan(s string, opts PANOptions, encrypt bool) (string, error) {
+	bin := opts.BINLength
+	if bin == 0 {
+		bin = 6
+	}
+	if bin != 6 && bin != 8 {
+		return "", errors.New("BIN length must be 6 or 8")
+	}
+	if len(opts.Tweak) > MaxTweakLen {
+		return "", ErrTweakTooLong
+	}
+
+	var digits []byte
+	for i := 0; i < len(s); i++ {
+		switch {
+		case isDigit(s[i]):
+			digits = append(digits, s[i])
+		case s[i] == ' ' || s[i] == '-':
+		default:
+			return "", ErrInvalidPAN
+		}
+	}
+	n := len(digits)
+	if n < panMinDigits || n > panMaxDigits {
+		return "", ErrInvalidPAN
+	}
+	if n-bin-panLastClear < 2 {
+		return "", errors.New("card number too short for the BIN length")
+	}
+	if !ValidCheckDigit(Luhn, string(digits)) {
+		return "", ErrLuhn
+	}
+
+	fix := n - panLastClear - 1 // the middle digit that restores the check
+	tweak := classTweak(DeriveTweak(string(tweakPAN), string(digits[:bin]), string(digits[n-panLastClear:])), opts.Tweak)
+	Y, err := c.run(10, string(digits[bin:fix]), tweak, encrypt)
+	if err != nil {
+		return "", err
+	}
+	copy(digits[bin:fix], Y)
+	digits[fix] = luhnFix(digits, fix)
+
+	var out strings.Builder
+	out.Grow(len(s))
+	j := 0
+	for i := 0; i < len(s); i++ {
+		if isDigit(s[i]) {
+			out.WriteByte(digits[j])
+			j++
+		} else {
+			out.WriteByte(s[i])
+		}
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,38 @@
+package cipher
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPAN(t *testing.T) {
+	for name, c := range fpeCores(t) {
+		for _, bin := range []int{6, 8} {
+			for _, s := range []string{
+				"4111 1111 1111 1111", "4111-1111-1111-1111", "5555555555554444",
+				"378282246310005", "6011000990139424", "30569309025904",
+			} {
+				opts := PANOptions{BINLength: bin}
+				e, err := c.EncryptPAN(s, opts)
+				if err != nil {
+					t.Fatalf("%s: EncryptPAN(%q, BIN %d): %v", name, s, bin, err)
+				}
+				plain := strings.NewReplacer(" ", "", "-", "").Replace(s)
+				token := strings.NewReplacer(" ", "", "-", "").Replace(e)
+				if len(e) != len(s) || !ValidCheckDigit(Luhn, token) {
+					t.Errorf("%s: EncryptPAN(%q) = %q, not a Luhn-valid card number", name, s, e)
+				}
+				if token[:bin] != plain[:bin] || token[len(token)-4:] != plain[len(plain)-4:] {
+					t.Errorf("%s: EncryptPAN(%q) = %q, BIN or last 4 changed", name, s, e)
+				}
+				if d, err := c.DecryptPAN(e, opts); err != nil || d != s {
+					t.Errorf("%s: DecryptPAN(%q) = %q, %v; want %q", name, e, d, err, s)
+				}
+			}
+		}
+		if _, err := c.EncryptPAN("4111111111111112", PANOptions{}); !errors.Is(err, ErrLuhn) {
+			t.Errorf("%s: EncryptPAN of a bad check digit: %v, want ErrLuhn", name, err)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package cipher
+
+import (
+	"errors"
+	"strings"
+)
+
+// ---------------------------
+// phone numbers
+// ---------------------------
+
+// PhoneOptions are settings for EncryptPhone.
+type PhoneOptions struct {
+	// PrefixDigits is the number of national digits after the country
+	// code (or after the trunk '0' of a national number) kept in clear,
+	// e.g. 2 for the Vietnamese carrier prefix "91" of +84 91 234 5678,
+	// or the length of an area code.
+	PrefixDigits int
+
+	// Tweak is bound next to the kept country code and prefix; see
+	// MaxTweakLen.
+	Tweak []byte
+}
+
+// ErrInvalidPhone is returned for input that is not a phone number.
+var ErrInvalidPhone = errors.New("not a phone number")
+
+var tweakPhone = []byte("PHONE")
+
+// phoneCC2 are the two-digit E.164 country codes. Codes starting with 1
+// or 7 have one digit; every other code has three.
+var phoneCC2 = map[string]bool{
+	"20": true, "27": true, "30": true, "31": true, "32": true, "33": true,
+	"34": true, "36": true, "39": true, "40": true, "41": true, "43": true,
+	"44": true, "45": true, "46": true, "47": true, "48": true, "49": true,
+	"51": true, "52": true, "53": true, "54": true, "55": true, "56": true,
+	"57": true, "58": true, "60": true, "61": true, "62": true, "63": true,
+	"64": true, "65": true, "66": true, "81": true, "82": true, "84": true,
+	"86": true, "90": true, "91": true, "92": true, "93": true, "94": true,
+	"95": true, "98": true,
+}
+
+// EncryptPhone encrypts a phone number in E.164 ("+84912345678") or
+// national ("0912 345 678") form. The country code, or the trunk '0' of a
+// national number, and PrefixDigits more digits stay in clear; the
+// remaining subscriber digits are encrypted as one domain, however they
+// are grouped. Spaces, dashes, dots and parentheses are kept where they
+// are, so with PrefixDigits 2 "+84 91 234 56 78" gives "+84 91 xxx xx xx".
+// As with EncryptPreserving, the subscriber digits start with '0' after
+// encryption only if they did before, so a national number never turns
+// into one that looks like an international "00" prefix.
+//
+// The clear digits are part of the tweak, so the same subscriber digits
+// under two country codes or prefixes encrypt differently.
+func (c *fpeCore) EncryptPhone(s string, opts PhoneOptions) (string, error) {
+	return c.phone(s, opts, true)
+}
+
+// DecryptPhone is the inverse of EncryptPhone with the same options.
+func (c *fpeCore) DecryptPhone(s string, opts PhoneOptions) (string, error) {
+	return c.phone(s, opts, false)
+}
+
+func (c *fpeCore) phone(s string, opts PhoneOptions, encrypt bool) (string, error) {
+	if len(opts.Tweak) > MaxTweakLen {
+		return "", ErrTweakTooLong
+	}
+	if opts.PrefixDigits < 0 {
+		return "", errors.New("negative PrefixDigits")
+	}
+	international := strings.HasPrefix(s, "+")
+	var digits []byte
+	for i := 0; i < len(s); i++ {
+		switch ch := s[i]; {
+		case isDigit(ch):
+			digits = append(digits, ch)
+		case ch == '+' && i == 0:
+		case ch == ' ' || ch == '-' || ch == '.' || ch == '(' || ch == ')':
+		default:
+			return "", ErrInvalidPhone
+		}
+	}
+	if len(digits) < 4 || len(digits) > 15 {
+		return "", ErrInvalidPhone
+	}
+
+	// clear part: country code or trunk prefix, then PrefixDigits
+	clear := 0
+	switch {
+	case international:
+		clear = phoneCCLen(digits)
+	case digits[0] == '0':
+		clear = 1
+	}
+	clear += opts.PrefixDigits
+	if clear >= len(digits) {
+		return "", errors.New("phone number has no subscriber digits")
+	}
+
+	tweak := classTweak(DeriveTweak(string(tweakPhone), string(digits[:clear])), opts.Tweak)
+	Y, err := c.digits(string(digits[clear:]), NumericNoLeadingZero, tweak, encrypt)
+	if err != nil {
+		return "", err
+	}
+	copy(digits[clear:], Y)
+
+	out := []byte(s)
+	j := 0
+	for i := range out {
+		if isDigit(out[i]) {
+			out[i] = digits[j]
+			j++
+		}
+	}
+	return string(out), nil
+}
+
+// phoneCCLen returns the length of the E.164 country code digits start with.
+func phoneCCLen(digits []byte) int {
+	switch {
+	case digits[0] == '1' || digits[0] == '7':
+		return 1
+	case phoneCC2[string(digits[:2])]:
+		return 2
+	default:
+		return 3
+	}
+}
@@ -0,0 +1,49 @@
+package cipher
+
+import (
+	"testing"
+)
+
+func TestPhone(t *testing.T) {
+	for name, c := range fpeCores(t) {
+		for _, tc := range []struct {
+			s     string
+			clear int // leading characters kept
+			opts  PhoneOptions
+		}{
+			{"+84912345678", 3, PhoneOptions{}},
+			{"+84 91 234 56 78", 6, PhoneOptions{PrefixDigits: 2}},
+			{"+1 (415) 555-2671", 8, PhoneOptions{PrefixDigits: 3}},
+			{"+44 20 7946 0958", 3, PhoneOptions{Tweak: []byte("crm.phone")}},
+			{"+358 9 1234", 4, PhoneOptions{}},
+			{"0912 345 678", 3, PhoneOptions{PrefixDigits: 2}},
+			{"020.7946.0958", 1, PhoneOptions{}},
+			// short subscriber groups go through the small-domain path
+			{"+84 91 2-3", 6, PhoneOptions{PrefixDigits: 2}},
+			{"0 9-1-2", 1, PhoneOptions{}},
+			{"+7 9 1-2", 4, PhoneOptions{PrefixDigits: 1}},
+		} {
+			enc, err := c.EncryptPhone(tc.s, tc.opts)
+			if err != nil {
+				t.Fatalf("%s: EncryptPhone(%q): %v", name, tc.s, err)
+			}
+			if len(enc) != len(tc.s) || enc[:tc.clear] != tc.s[:tc.clear] {
+				t.Errorf("%s: EncryptPhone(%q) = %q, country code or prefix changed", name, tc.s, enc)
+			}
+			for i := tc.clear; i < len(tc.s); i++ {
+				if isDigit(tc.s[i]) != isDigit(enc[i]) || !isDigit(tc.s[i]) && tc.s[i] != enc[i] {
+					t.Errorf("%s: EncryptPhone(%q) = %q, grouping changed", name, tc.s, enc)
+					break
+				}
+			}
+			if dec, err := c.DecryptPhone(enc, tc.opts); err != nil || dec != tc.s {
+				t.Errorf("%s: DecryptPhone(%q) = %q, %v; want %q", name, enc, dec, err, tc.s)
+			}
+		}
+		for _, bad := range []string{"123", "+84 91x 234", "1234567890123456", "+84"} {
+			if _, err := c.EncryptPhone(bad, PhoneOptions{}); err == nil {
+				t.Errorf("%s: EncryptPhone(%q) succeeded", name, bad)
+			}
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package cipher
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+func TestRange(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for name, c := range fpeCores(t) {
+		for _, r := range [][2]int64{
+			{0, 120}, {1, 5000000}, {-50, 50}, {7, 7}, {0, 1},
+			{1000, 1 << 20}, {math.MinInt64, math.MaxInt64},
+		} {
+			min, max := r[0], r[1]
+			samples := []int64{min, max}
+			for i := 0; i < 50; i++ {
+				v := rng.Uint64()
+				if span := uint64(max) - uint64(min); span != math.MaxUint64 {
+					v %= span + 1
+				}
+				samples = append(samples, min+int64(v))
+			}
+			total := 0
+			for _, n := range samples {
+				e, walks, err := c.EncryptRange(n, min, max, RangeOptions{})
+				if err != nil {
+					t.Fatalf("%s: EncryptRange(%d, %d, %d): %v", name, n, min, max, err)
+				}
+				if e < min || e > max {
+					t.Errorf("%s: EncryptRange(%d, %d, %d) = %d, out of range", name, n, min, max, e)
+				}
+				if walks < 0 || walks > maxCycleWalks {
+					t.Errorf("%s: EncryptRange(%d, %d, %d) took %d walks", name, n, min, max, walks)
+				}
+				total += walks
+				d, _, err := c.DecryptRange(e, min, max, RangeOptions{})
+				if err != nil || d != n {
+					t.Errorf("%s: DecryptRange(%d, %d, %d) = %d, %v; want %d", name, e, min, max, d, err, n)
+				}
+			}
+			// the enclosing domain is less than twice the range
+			if avg := float64(total) / float64(len(samples)); avg > 2 {
+				t.Errorf("%s: range [%d, %d] averaged %.1f walks", name, min, max, avg)
+			}
+		}
+		if _, _, err := c.EncryptRange(121, 0, 120, RangeOptions{}); !errors.Is(err, ErrOutOfRange) {
+			t.Errorf("%s: value above max: error = %v, want ErrOutOfRange", name, err)
+		}
+		if _, _, err := c.EncryptRange(5, 10, 0, RangeOptions{}); !errors.Is(err, ErrOutOfRange) {
+			t.Errorf("%s: empty range: error = %v, want ErrOutOfRange", name, err)
+		}
+	}
+}
+
+func TestRangeBijection(t *testing.T) {
+	for name, c := range fpeCores(t) {
+		for _, size := range []int64{1, 2, 100, 1000, 5000} {
+			seen := make(map[int64]bool, size)
+			for n := int64(0); n < size; n++ {
+				e, _, err := c.EncryptRange(n+10, 10, 10+size-1, RangeOptions{Tweak: []byte("age")})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if seen[e] {
+					t.Fatalf("%s: range of %d: %d encrypted twice", name, size, e)
+				}
+				seen[e] = true
+			}
+		}
+	}
+}
+
+func TestRangeBig(t *testing.T) {
+	min, _ := new(big.Int).SetString("-100000000000000000000000000000", 10)
+	max, _ := new(big.Int).SetString("100000000000000000000000000000", 10)
+	for name, c := range fpeCores(t) {
+		for _, s := range []string{"0", "-99999999999999999999999999999", "100000000000000000000000000000", "123456789"} {
+			n, _ := new(big.Int).SetString(s, 10)
+			e, walks, err := c.EncryptRangeBig(n, min, max, RangeOptions{})
+			if err != nil {
+				t.Fatalf("%s: EncryptRangeBig(%s): %v", name, n, err)
+			}
+			if e.Cmp(min) < 0 || e.Cmp(max) > 0 || walks > maxCycleWalks {
+				t.Errorf("%s: EncryptRangeBig(%s) = %s after %d walks, out of range", name, n, e, walks)
+			}
+			if d, _, err := c.DecryptRangeBig(e, min, max, RangeOptions{}); err != nil || d.Cmp(n) != 0 {
+				t.Errorf("%s: DecryptRangeBig(%s) = %s, %v; want %s", name, e, d, err, n)
+			}
+		}
+	}
+}
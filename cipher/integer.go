@@ -0,0 +1,264 @@
+package cipher
+
+import (
+	"errors"
+	"math"
+	"math/big"
+)
+
+// ---------------------------
+// typed integers
+// ---------------------------
+
+// IntCipher encrypts integers as integers. A value keeps its sign and its
+// number of decimal digits, and the result always fits the type, so
+// callers need no string formatting or parsing of their own.
+type IntCipher interface {
+	EncryptInt64(v int64) (int64, error)
+	DecryptInt64(v int64) (int64, error)
+	EncryptUint64(v uint64) (uint64, error)
+	DecryptUint64(v uint64) (uint64, error)
+	EncryptBigInt(v *big.Int) (*big.Int, error)
+	DecryptBigInt(v *big.Int) (*big.Int, error)
+}
+
+var (
+	_ IntCipher = (*SubstitutionCipher)(nil)
+	_ IntCipher = (*FPECipher)(nil)
+	_ IntCipher = (*FF31Cipher)(nil)
+)
+
+var (
+	maxInt64Mag  = new(big.Int).SetUint64(math.MaxInt64)
+	minInt64Mag  = new(big.Int).SetUint64(1 << 63) // |math.MinInt64|
+	maxUint64Mag = new(big.Int).SetUint64(math.MaxUint64)
+	bigTen       = big.NewInt(10)
+)
+
+var tweakInt = "INT"
+
+// intDomain returns [lo, hi], the magnitudes with as many digits as m,
+// with hi capped at limit (nil for no cap). 0 shares the one-digit domain
+// [0, 9] unless negative, where it cannot occur: [1, 9].
+func intDomain(m *big.Int, neg bool, limit *big.Int) (lo, hi *big.Int) {
+	digits := len(m.String())
+	hi = new(big.Int).Exp(bigTen, big.NewInt(int64(digits)), nil)
+	hi.Sub(hi, big.NewInt(1))
+	if limit != nil && hi.Cmp(limit) > 0 {
+		hi.Set(limit)
+	}
+	lo = new(big.Int).Exp(bigTen, big.NewInt(int64(digits-1)), nil)
+	if digits == 1 && !neg {
+		lo.SetInt64(0)
+	}
+	return lo, hi
+}
+
+// int64Parts splits v into its sign and magnitude and returns the cap for
+// that sign.
+func int64Parts(v int64) (neg bool, m, limit *big.Int) {
+	if v < 0 {
+		return true, new(big.Int).Neg(big.NewInt(v)), minInt64Mag
+	}
+	return false, big.NewInt(v), maxInt64Mag
+}
+
+// int64From reverses int64Parts; m is within the cap, so it cannot overflow.
+func int64From(neg bool, m *big.Int) int64 {
+	if neg {
+		return new(big.Int).Neg(m).Int64()
+	}
+	return m.Int64()
+}
+
+func bigParts(v *big.Int) (neg bool, m *big.Int, err error) {
+	if v == nil {
+		return false, nil, errors.New("nil *big.Int")
+	}
+	return v.Sign() < 0, new(big.Int).Abs(v), nil
+}
+
+func bigFrom(neg bool, m *big.Int) *big.Int {
+	if neg {
+		return m.Neg(m)
+	}
+	return m
+}
+
+// ---- FPE ----
+
+// EncryptInt64 encrypts v into an int64 with the same sign and number of
+// digits: the magnitude is encrypted over all magnitudes of that length
+// (capped at the int64 limit for 19 digits), as in EncryptRange.
+func (c *fpeCore) EncryptInt64(v int64) (int64, error) {
+	neg, m, limit := int64Parts(v)
+	r, err := c.magnitude(m, neg, limit, true)
+	if err != nil {
+		return 0, err
+	}
+	return int64From(neg, r), nil
+}
+
+// DecryptInt64 is the inverse of EncryptInt64.
+func (c *fpeCore) DecryptInt64(v int64) (int64, error) {
+	neg, m, limit := int64Parts(v)
+	r, err := c.magnitude(m, neg, limit, false)
+	if err != nil {
+		return 0, err
+	}
+	return int64From(neg, r), nil
+}
+
+// EncryptUint64 is EncryptInt64 for uint64.
+func (c *fpeCore) EncryptUint64(v uint64) (uint64, error) {
+	r, err := c.magnitude(new(big.Int).SetUint64(v), false, maxUint64Mag, true)
+	if err != nil {
+		return 0, err
+	}
+	return r.Uint64(), nil
+}
+
+// DecryptUint64 is the inverse of EncryptUint64.
+func (c *fpeCore) DecryptUint64(v uint64) (uint64, error) {
+	r, err := c.magnitude(new(big.Int).SetUint64(v), false, maxUint64Mag, false)
+	if err != nil {
+		return 0, err
+	}
+	return r.Uint64(), nil
+}
+
+// EncryptBigInt is EncryptInt64 without a cap on the magnitude. Values
+// that fit an int64 (below 19 digits) encrypt the same as with
+// EncryptInt64.
+func (c *fpeCore) EncryptBigInt(v *big.Int) (*big.Int, error) {
+	neg, m, err := bigParts(v)
+	if err != nil {
+		return nil, err
+	}
+	r, err := c.magnitude(m, neg, nil, true)
+	if err != nil {
+		return nil, err
+	}
+	return bigFrom(neg, r), nil
+}
+
+// DecryptBigInt is the inverse of EncryptBigInt.
+func (c *fpeCore) DecryptBigInt(v *big.Int) (*big.Int, error) {
+	neg, m, err := bigParts(v)
+	if err != nil {
+		return nil, err
+	}
+	r, err := c.magnitude(m, neg, nil, false)
+	if err != nil {
+		return nil, err
+	}
+	return bigFrom(neg, r), nil
+}
+
+// magnitude encrypts m over its digit-count domain. The domain bounds and
+// the sign are part of the tweak.
+func (c *fpeCore) magnitude(m *big.Int, neg bool, limit *big.Int, encrypt bool) (*big.Int, error) {
+	lo, hi := intDomain(m, neg, limit)
+	sign := "+"
+	if neg {
+		sign = "-"
+	}
+	size := new(big.Int).Sub(hi, lo)
+	size.Add(size, big.NewInt(1))
+	t := DeriveTweak(tweakInt, sign, lo.String(), hi.String())
+	r, _, err := c.permuteInt(size, new(big.Int).Sub(m, lo), t, encrypt)
+	if err != nil {
+		return nil, err
+	}
+	return r.Add(r, lo), nil
+}
+
+// ---- substitution ----
+
+// EncryptInt64 encrypts v with the EncryptNumber tables into an int64 with
+// the same sign and number of digits. A 19-digit result above the int64
+// limit is encrypted again (cycle-walking) until it fits, so nothing
+// overflows and DecryptInt64 walks back the same way.
+func (c *SubstitutionCipher) EncryptInt64(v int64) (int64, error) {
+	neg, m, limit := int64Parts(v)
+	r, err := c.magnitude(m, limit, true)
+	if err != nil {
+		return 0, err
+	}
+	return int64From(neg, r), nil
+}
+
+// DecryptInt64 is the inverse of EncryptInt64.
+func (c *SubstitutionCipher) DecryptInt64(v int64) (int64, error) {
+	neg, m, limit := int64Parts(v)
+	r, err := c.magnitude(m, limit, false)
+	if err != nil {
+		return 0, err
+	}
+	return int64From(neg, r), nil
+}
+
+// EncryptUint64 is EncryptInt64 for uint64.
+func (c *SubstitutionCipher) EncryptUint64(v uint64) (uint64, error) {
+	r, err := c.magnitude(new(big.Int).SetUint64(v), maxUint64Mag, true)
+	if err != nil {
+		return 0, err
+	}
+	return r.Uint64(), nil
+}
+
+// DecryptUint64 is the inverse of EncryptUint64.
+func (c *SubstitutionCipher) DecryptUint64(v uint64) (uint64, error) {
+	r, err := c.magnitude(new(big.Int).SetUint64(v), maxUint64Mag, false)
+	if err != nil {
+		return 0, err
+	}
+	return r.Uint64(), nil
+}
+
+// EncryptBigInt is EncryptInt64 without a cap on the magnitude.
+func (c *SubstitutionCipher) EncryptBigInt(v *big.Int) (*big.Int, error) {
+	neg, m, err := bigParts(v)
+	if err != nil {
+		return nil, err
+	}
+	r, err := c.magnitude(m, nil, true)
+	if err != nil {
+		return nil, err
+	}
+	return bigFrom(neg, r), nil
+}
+
+// DecryptBigInt is the inverse of EncryptBigInt.
+func (c *SubstitutionCipher) DecryptBigInt(v *big.Int) (*big.Int, error) {
+	neg, m, err := bigParts(v)
+	if err != nil {
+		return nil, err
+	}
+	r, err := c.magnitude(m, nil, false)
+	if err != nil {
+		return nil, err
+	}
+	return bigFrom(neg, r), nil
+}
+
+// magnitude maps the digits of m with the number tables (first digit in
+// 1..9, so the digit count is kept) and walks until the result is within
+// limit. The tables form a permutation of order at most lcm(20, 30), so
+// the walk ends well inside maxCycleWalks.
+func (c *SubstitutionCipher) magnitude(m, limit *big.Int, encrypt bool) (*big.Int, error) {
+	s := m.String()
+	r := new(big.Int)
+	for walks := 0; walks <= maxCycleWalks; walks++ {
+		if encrypt {
+			s = c.EncryptNumberMode(s, NumericNoLeadingZero)
+		} else {
+			s = c.DecryptNumberMode(s, NumericNoLeadingZero)
+		}
+		r.SetString(s, 10)
+		if limit == nil || r.Cmp(limit) <= 0 {
+			return r, nil
+		}
+	}
+	return nil, ErrCycleWalkLimit
+}
@@ -0,0 +1,119 @@
+package cipher
+
+import (
+	"math"
+	"math/big"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+func intCiphers(t *testing.T) map[string]IntCipher {
+	t.Helper()
+	ciphers := map[string]IntCipher{
+		"substitution": NewSubstitutionCipher("key").(*SubstitutionCipher),
+	}
+	for name, c := range fpeCores(t) {
+		ciphers[name] = c
+	}
+	return ciphers
+}
+
+// digitCount is the number of decimal digits of |v|.
+func digitCount(s string) int {
+	if s[0] == '-' {
+		return len(s) - 1
+	}
+	return len(s)
+}
+
+func TestInt64Properties(t *testing.T) {
+	samples := []int64{
+		math.MinInt64, math.MinInt64 + 1, math.MaxInt64, math.MaxInt64 - 1,
+		0, 1, -1, 9, -9, 10, -10, 99, 100,
+		999999999999999999, 1000000000000000000, -1000000000000000000,
+	}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		// spread over every digit count
+		v := rng.Int63() >> rng.Intn(63)
+		if rng.Intn(2) == 0 {
+			v = -v
+		}
+		samples = append(samples, v)
+	}
+	for name, c := range intCiphers(t) {
+		for _, v := range samples {
+			e, err := c.EncryptInt64(v)
+			if err != nil {
+				t.Fatalf("%s: EncryptInt64(%d): %v", name, v, err)
+			}
+			if (e < 0) != (v < 0) {
+				t.Errorf("%s: %d encrypts to %d, sign changed", name, v, e)
+			}
+			if a, b := strconv.FormatInt(v, 10), strconv.FormatInt(e, 10); digitCount(a) != digitCount(b) {
+				t.Errorf("%s: %d encrypts to %d, digit count changed", name, v, e)
+			}
+			d, err := c.DecryptInt64(e)
+			if err != nil || d != v {
+				t.Errorf("%s: DecryptInt64(%d) = %d, %v; want %d", name, e, d, err, v)
+			}
+		}
+	}
+}
+
+func TestUint64Properties(t *testing.T) {
+	samples := []uint64{0, 1, 9, 10, math.MaxUint64, math.MaxUint64 - 1, 1 << 63, 10000000000000000000}
+	rng := rand.New(rand.NewSource(2))
+	for i := 0; i < 100; i++ {
+		samples = append(samples, rng.Uint64()>>rng.Intn(64))
+	}
+	for name, c := range intCiphers(t) {
+		for _, v := range samples {
+			e, err := c.EncryptUint64(v)
+			if err != nil {
+				t.Fatalf("%s: EncryptUint64(%d): %v", name, v, err)
+			}
+			if len(strconv.FormatUint(v, 10)) != len(strconv.FormatUint(e, 10)) {
+				t.Errorf("%s: %d encrypts to %d, digit count changed", name, v, e)
+			}
+			d, err := c.DecryptUint64(e)
+			if err != nil || d != v {
+				t.Errorf("%s: DecryptUint64(%d) = %d, %v; want %d", name, e, d, err, v)
+			}
+		}
+	}
+}
+
+func TestBigIntProperties(t *testing.T) {
+	var samples []*big.Int
+	for _, s := range []string{
+		"0", "-1", "9223372036854775807", "-9223372036854775808",
+		"18446744073709551616", "-18446744073709551616",
+		"123456789012345678901234567890", "-99999999999999999999999999999999999999",
+	} {
+		v, _ := new(big.Int).SetString(s, 10)
+		samples = append(samples, v)
+	}
+	for name, c := range intCiphers(t) {
+		for _, v := range samples {
+			e, err := c.EncryptBigInt(v)
+			if err != nil {
+				t.Fatalf("%s: EncryptBigInt(%s): %v", name, v, err)
+			}
+			if e.Sign()*v.Sign() < 0 || digitCount(e.String()) != digitCount(v.String()) {
+				t.Errorf("%s: %s encrypts to %s, sign or digit count changed", name, v, e)
+			}
+			d, err := c.DecryptBigInt(e)
+			if err != nil || d.Cmp(v) != 0 {
+				t.Errorf("%s: DecryptBigInt(%s) = %s, %v; want %s", name, e, d, err, v)
+			}
+		}
+		// values that fit an int64 encrypt as with EncryptInt64
+		e64, _ := c.EncryptInt64(-12345)
+		eb, _ := c.EncryptBigInt(big.NewInt(-12345))
+		if eb.Int64() != e64 {
+			t.Errorf("%s: EncryptBigInt(-12345) = %s, EncryptInt64 = %d", name, eb, e64)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package cipher
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Options carries algorithm-specific settings for New, typically read
+// from a config file or command-line flags.
+type Options map[string]string
+
+// Factory builds a cipher from raw key material and options.
+type Factory func(key []byte, opts Options) (CipherV2, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+func init() {
+	Register("subst-v1", func(key []byte, opts Options) (CipherV2, error) {
+		if err := opts.check("scripts", "ranges"); err != nil {
+			return nil, err
+		}
+		if opts["scripts"] == "" && opts["ranges"] == "" {
+			return NewSubstitutionCipher(string(key)).(*SubstitutionCipher), nil
+		}
+		alphabets, err := optionAlphabets(opts)
+		if err != nil {
+			return nil, err
+		}
+		return NewRuneSubstitutionCipher(string(key), alphabets...)
+	})
+	Register("ff1", func(key []byte, opts Options) (CipherV2, error) {
+		if err := opts.check("scripts"); err != nil {
+			return nil, err
+		}
+		c, err := NewFPECipher(key)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.registerScripts(opts["scripts"]); err != nil {
+			return nil, err
+		}
+		return c, nil
+	})
+	Register("ff3-1", func(key []byte, opts Options) (CipherV2, error) {
+		if err := opts.check("scripts"); err != nil {
+			return nil, err
+		}
+		c, err := NewFF31Cipher(key)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.registerScripts(opts["scripts"]); err != nil {
+			return nil, err
+		}
+		return c, nil
+	})
+}
+
+// Register makes a cipher available to New under name.
+// It panics if name is empty, f is nil, or name is already taken,
+// so it is meant to be called from init functions.
+func Register(name string, f Factory) {
+	if name == "" {
+		panic("cipher: Register with empty name")
+	}
+	if f == nil {
+		panic("cipher: Register factory is nil for " + name)
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, dup := registry[name]; dup {
+		panic("cipher: Register called twice for " + name)
+	}
+	registry[name] = f
+}
+
+// New builds the cipher registered under name.
+func New(name string, key []byte, opts Options) (CipherV2, error) {
+	registryMu.RLock()
+	f, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown cipher %q (registered: %v)", name, Names())
+	}
+	return f(key, opts)
+}
+
+// Names returns the registered cipher names in sorted order.
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// registerScripts registers the comma-separated scripts of the "scripts"
+// option, e.g. "vietnamese" or "cyrillic,greek".
+func (c *fpeCore) registerScripts(list string) error {
+	if list == "" {
+		return nil
+	}
+	for _, name := range strings.Split(list, ",") {
+		if err := c.RegisterScript(strings.TrimSpace(name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// optionAlphabets collects the alphabets of the "scripts" option (lower and
+// upper case of each) and the "ranges" option for the rune substitution.
+func optionAlphabets(opts Options) ([]*Alphabet, error) {
+	var out []*Alphabet
+	if list := opts["scripts"]; list != "" {
+		for _, name := range strings.Split(list, ",") {
+			a, ok := scripts[strings.TrimSpace(name)]
+			if !ok {
+				return nil, fmt.Errorf("unknown script %q", name)
+			}
+			out = append(out, a[0], a[1])
+		}
+	}
+	if list := opts["ranges"]; list != "" {
+		ranges, err := parseRuneRanges(list)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ranges...)
+	}
+	return out, nil
+}
+
+// check rejects options outside known, so a typo in a config file
+// fails loudly instead of being ignored.
+func (o Options) check(known ...string) error {
+	for k := range o {
+		found := false
+		for _, name := range known {
+			if k == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown option %q", k)
+		}
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package cipher
 
 import (
+	"context"
 	"crypto/sha256"
 	"math/rand"
 	"strings"
@@ -14,6 +15,10 @@ type SubstitutionCipher struct {
 	// special mapping for first digit: domain { '1'..'9' } -> { '1'..'9' }
 	firstDigitEnc [10]byte // use index 1..9
 	firstDigitDec [10]byte // use index 1..9
+
+	// rune-level mode (NewRuneSubstitutionCipher); nil for the byte mode
+	runeEnc map[rune]rune
+	runeDec map[rune]rune
 }
 
 // update NewSubstitutionCipher: create 2 number permutations
@@ -79,7 +84,11 @@ func seedFromKey(key string) int64 {
 }
 
 // Encrypt/Decrypt: ASCII-only (byte-wise). Non-ASCII characters are kept as bytes.
+// A cipher from NewRuneSubstitutionCipher also maps the runes of its alphabets.
 func (c *SubstitutionCipher) Encrypt(s string) string {
+	if c.runeEnc != nil {
+		return substituteRunes(s, c.runeEnc, &c.enc)
+	}
 	var b strings.Builder
 	b.Grow(len(s))
 	for i := 0; i < len(s); i++ {
@@ -90,6 +99,9 @@ func (c *SubstitutionCipher) Encrypt(s string) string {
 }
 
 func (c *SubstitutionCipher) Decrypt(s string) string {
+	if c.runeDec != nil {
+		return substituteRunes(s, c.runeDec, &c.dec)
+	}
 	var b strings.Builder
 	b.Grow(len(s))
 	for i := 0; i < len(s); i++ {
@@ -100,66 +112,86 @@ func (c *SubstitutionCipher) Decrypt(s string) string {
 }
 
 // EncryptNumber encrypts a (possibly signed) numeric string.
+// It is EncryptNumberMode with NumericNoLeadingZero.
+func (c *SubstitutionCipher) EncryptNumber(s string) string {
+	return c.EncryptNumberMode(s, NumericNoLeadingZero)
+}
+
+// DecryptNumber decrypts a (possibly signed) numeric string produced by EncryptNumber.
+func (c *SubstitutionCipher) DecryptNumber(s string) string {
+	return c.DecryptNumberMode(s, NumericNoLeadingZero)
+}
+
+// EncryptNumberMode encrypts a (possibly signed) numeric string.
 // Behavior:
 //   - Optional leading '-' is preserved.
+//   - Non-digit characters (besides an optional leading '-') cause a no-op (returns s).
+//
+// NumericNoLeadingZero:
 //   - The first digit of the numeric part is mapped via the {1..9} table,
 //     ensuring the output never starts with '0'.
-//   - If the numeric part starts with '0', we map it with the normal table;
-//     if it still becomes '0', we replace it with a deterministic non-zero
-//     (mapping of '1' from the first-digit table).
-//   - Non-digit characters (besides an optional leading '-') cause a no-op (returns s).
+//   - If the numeric part starts with '0', the '0' is kept, so "01234"
+//     encrypts to another string starting with '0' and round-trips.
 //   - "-0" is normalized to "0".
-func (c *SubstitutionCipher) EncryptNumber(s string) string {
-	if len(s) == 0 {
-		return s
+//
+// NumericFixedLength:
+//   - Every digit is mapped via the {0..9} table, a bijection on all
+//     digit strings of the same length (leading zeros included).
+//   - "-0" is kept as is.
+func (c *SubstitutionCipher) EncryptNumberMode(s string, mode NumericMode) string {
+	neg, num, ok := splitNumber(s)
+	if !ok {
+		return s // policy: non-digit -> no-op
 	}
 
-	// handle optional leading '-'
-	neg := false
-	num := s
-	if s[0] == '-' {
-		neg = true
-		num = s[1:]
-		if len(num) == 0 {
-			return s // "-" only -> no-op
-		}
+	out := make([]byte, len(num))
+	for i := 0; i < len(num); i++ {
+		out[i] = c.enc[num[i]]
 	}
 
-	// validate digits
-	for i := 0; i < len(num); i++ {
-		if num[i] < '0' || num[i] > '9' {
-			return s // policy: non-digit -> no-op
+	if mode == NumericNoLeadingZero {
+		// normalize "-0" -> "0"
+		if neg && num == "0" {
+			return "0"
+		}
+		// first digit: {1..9} -> {1..9}, '0' stays '0'
+		if num[0] == '0' {
+			out[0] = '0'
+		} else {
+			out[0] = c.firstDigitEnc[num[0]-'0']
 		}
 	}
 
-	// normalize "-0" -> "0"
-	if neg && num == "0" {
-		return "0"
+	if neg {
+		return "-" + string(out)
 	}
+	return string(out)
+}
 
-	// empty numeric (shouldn't happen here), just return
-	if len(num) == 0 {
-		return s
+// DecryptNumberMode decrypts a (possibly signed) numeric string produced by
+// EncryptNumberMode with the same mode.
+func (c *SubstitutionCipher) DecryptNumberMode(s string, mode NumericMode) string {
+	neg, num, ok := splitNumber(s)
+	if !ok {
+		return s // policy: non-digit -> no-op
 	}
 
 	out := make([]byte, len(num))
-
-	// first digit (ensure non-zero output)
-	if num[0] < '1' || num[0] > '9' {
-		// input starts with '0'
-		m := c.enc[num[0]]
-		if m == '0' {
-			// deterministic non-zero fallback
-			m = c.firstDigitEnc[1]
-		}
-		out[0] = m
-	} else {
-		out[0] = c.firstDigitEnc[num[0]-'0']
+	for i := 0; i < len(num); i++ {
+		out[i] = c.dec[num[i]]
 	}
 
-	// remaining digits
-	for i := 1; i < len(num); i++ {
-		out[i] = c.enc[num[i]]
+	if mode == NumericNoLeadingZero {
+		// first digit: reverse the {1..9} table, '0' stays '0'
+		if num[0] == '0' {
+			out[0] = '0'
+		} else {
+			out[0] = c.firstDigitDec[num[0]-'0']
+		}
+		// normalize "-0" -> "0"
+		if string(out) == "0" {
+			return "0"
+		}
 	}
 
 	if neg {
@@ -168,59 +200,57 @@ func (c *SubstitutionCipher) EncryptNumber(s string) string {
 	return string(out)
 }
 
-// DecryptNumber decrypts a (possibly signed) numeric string produced by EncryptNumber.
-// Behavior:
-//   - Preserves an optional leading '-'.
-//   - First digit of the numeric part is reversed via the {1..9} reverse table
-//     if it is in '1'..'9'; otherwise falls back to the normal table.
-//   - Non-digit characters (besides an optional leading '-') cause a no-op (returns s).
-//   - "-0" is normalized back to "0".
-func (c *SubstitutionCipher) DecryptNumber(s string) string {
-	if len(s) == 0 {
-		return s
+// splitNumber splits an optionally signed digit string into its sign and digits.
+func splitNumber(s string) (neg bool, num string, ok bool) {
+	if !isNumber(s) {
+		return false, "", false
 	}
-
-	// handle optional leading '-'
-	neg := false
-	num := s
 	if s[0] == '-' {
-		neg = true
-		num = s[1:]
-		if len(num) == 0 {
-			return s // "-" only -> no-op
-		}
-	}
-
-	// validate digits
-	for i := 0; i < len(num); i++ {
-		if num[i] < '0' || num[i] > '9' {
-			return s // policy: non-digit -> no-op
-		}
+		return true, s[1:], true
 	}
+	return false, s, true
+}
 
-	// decrypt numeric part
-	out := make([]byte, len(num))
+// ---------------------------
+// CipherV2
+// ---------------------------
 
-	// first digit
-	if num[0] >= '1' && num[0] <= '9' {
-		out[0] = c.firstDigitDec[num[0]-'0']
-	} else {
-		out[0] = c.dec[num[0]]
+// EncryptContext is Encrypt with the CipherV2 signature.
+func (c *SubstitutionCipher) EncryptContext(ctx context.Context, s string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
 	}
+	return c.Encrypt(s), nil
+}
 
-	// remaining digits
-	for i := 1; i < len(num); i++ {
-		out[i] = c.dec[num[i]]
+// DecryptContext is Decrypt with the CipherV2 signature.
+func (c *SubstitutionCipher) DecryptContext(ctx context.Context, s string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
 	}
+	return c.Decrypt(s), nil
+}
 
-	plain := string(out)
+// EncryptNumberContext is EncryptNumber with the CipherV2 signature.
+// Unlike EncryptNumber, non-numeric input is reported as ErrNotNumber
+// instead of being returned unchanged.
+func (c *SubstitutionCipher) EncryptNumberContext(ctx context.Context, s string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if !isNumber(s) {
+		return "", ErrNotNumber
+	}
+	return c.EncryptNumber(s), nil
+}
 
-	// normalize "-0" -> "0"
-	if plain == "0" {
-		return "0"
+// DecryptNumberContext is DecryptNumber with the CipherV2 signature.
+func (c *SubstitutionCipher) DecryptNumberContext(ctx context.Context, s string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
 	}
-	if neg {
-		return "-" + plain
+	if !isNumber(s) {
+		return "", ErrNotNumber
 	}
-	return plain
+	return c.DecryptNumber(s), nil
 }
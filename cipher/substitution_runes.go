@@ -0,0 +1,117 @@
+package cipher
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ---------------------------
+// rune-level substitution
+// ---------------------------
+
+// AlphabetRange returns the alphabet of the runes lo..hi inclusive, in
+// code point order. Surrogates (U+D800-U+DFFF) are skipped so every rune
+// of the alphabet encodes to valid UTF-8.
+func AlphabetRange(lo, hi rune) (*Alphabet, error) {
+	if lo < 0 || hi > utf8.MaxRune || lo > hi {
+		return nil, fmt.Errorf("invalid rune range %U-%U", lo, hi)
+	}
+	var runes []rune
+	for r := lo; r <= hi; r++ {
+		if utf8.ValidRune(r) {
+			runes = append(runes, r)
+		}
+	}
+	return newAlphabet(runes)
+}
+
+// NewRuneSubstitutionCipher returns a SubstitutionCipher that works rune by
+// rune: each alphabet gets its own keyed permutation, so a rune is replaced
+// by another rune of the same alphabet (same script and case when the
+// alphabets are chosen that way, e.g. AlphabetCyrillicLower). ASCII letters
+// and digits outside the alphabets keep the byte-level tables of
+// NewSubstitutionCipher; every other rune is copied as-is.
+//
+// Alphabets must not overlap. The permutation of an alphabet depends only
+// on the key and the alphabet, so adding another alphabet later does not
+// change the ciphertext of text that does not use it. Valid UTF-8 input
+// gives valid UTF-8 output with the same number of runes; invalid bytes
+// are copied through unchanged so that Decrypt restores them.
+func NewRuneSubstitutionCipher(key string, alphabets ...*Alphabet) (*SubstitutionCipher, error) {
+	c := NewSubstitutionCipher(key).(*SubstitutionCipher)
+	c.runeEnc = make(map[rune]rune)
+	c.runeDec = make(map[rune]rune)
+	for i, a := range alphabets {
+		if a == nil {
+			return nil, errors.New("nil alphabet")
+		}
+		for _, r := range a.runes {
+			if _, dup := c.runeEnc[r]; dup {
+				return nil, fmt.Errorf("alphabet %d overlaps an earlier one at %q", i, r)
+			}
+			if !utf8.ValidRune(r) {
+				return nil, fmt.Errorf("alphabet %d contains invalid rune %U", i, r)
+			}
+		}
+		sh := append([]rune(nil), a.runes...)
+		r := rand.New(rand.NewSource(seedFromKey(key + "\x00rune-alphabet\x00" + a.String())))
+		r.Shuffle(len(sh), func(i, j int) { sh[i], sh[j] = sh[j], sh[i] })
+		for j, from := range a.runes {
+			c.runeEnc[from] = sh[j]
+			c.runeDec[sh[j]] = from
+		}
+	}
+	return c, nil
+}
+
+// substituteRunes maps s rune by rune with m, falling back to the byte
+// table for ASCII.
+func substituteRunes(s string, m map[rune]rune, table *[256]byte) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if to, ok := m[r]; ok { // never U+FFFD, so never an invalid byte
+			b.WriteRune(to)
+		} else if r < utf8.RuneSelf {
+			b.WriteByte(table[s[i]])
+		} else {
+			b.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	return b.String()
+}
+
+// parseRuneRanges parses a comma-separated list of hexadecimal code point
+// ranges such as "0400-04FF,0370-03FF" (a "U+" prefix is allowed).
+func parseRuneRanges(list string) ([]*Alphabet, error) {
+	var out []*Alphabet
+	for _, part := range strings.Split(list, ",") {
+		lo, hi, ok := strings.Cut(strings.TrimSpace(part), "-")
+		if !ok {
+			hi = lo
+		}
+		l, err1 := parseCodePoint(lo)
+		h, err2 := parseCodePoint(hi)
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("invalid rune range %q", part)
+		}
+		a, err := AlphabetRange(l, h)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, nil
+}
+
+func parseCodePoint(s string) (rune, error) {
+	s = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(s)), "U+")
+	v, err := strconv.ParseUint(s, 16, 32)
+	return rune(v), err
+}
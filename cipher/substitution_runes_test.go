@@ -0,0 +1,111 @@
+package cipher
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestRuneSubstitutionMixedScripts(t *testing.T) {
+	alphabets := []*Alphabet{
+		AlphabetVietnameseLower, AlphabetVietnameseUpper,
+		AlphabetCyrillicLower, AlphabetCyrillicUpper,
+		AlphabetGreekLower, AlphabetGreekUpper,
+	}
+	c, err := NewRuneSubstitutionCipher("key", alphabets...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alphabetOf := func(r rune) *Alphabet {
+		for _, a := range alphabets {
+			if a.Contains(r) {
+				return a
+			}
+		}
+		return nil
+	}
+	for _, s := range []string{
+		"Hello, Nguyễn Văn Đức!",
+		"Москва и Αθήνα, Hà Nội và Paris",
+		"ΑΒΓ абв ĂÂÊ xyz 2024-01-31",
+		"mixed: Ωmega-Жук-Đường-Zebra 😀",
+		"",
+	} {
+		enc := c.Encrypt(s)
+		if !utf8.ValidString(enc) {
+			t.Fatalf("Encrypt(%q) = %q, not valid UTF-8", s, enc)
+		}
+		in, out := []rune(s), []rune(enc)
+		if len(in) != len(out) {
+			t.Fatalf("Encrypt(%q) = %q, rune count changed", s, enc)
+		}
+		for i := range in {
+			if a := alphabetOf(in[i]); a != nil && !a.Contains(out[i]) {
+				t.Errorf("Encrypt(%q): %q became %q, outside its alphabet", s, in[i], out[i])
+			}
+		}
+		if dec := c.Decrypt(enc); dec != s {
+			t.Errorf("Decrypt(%q) = %q, want %q", enc, dec, s)
+		}
+	}
+}
+
+func TestRuneSubstitutionInvalidUTF8(t *testing.T) {
+	c, err := NewRuneSubstitutionCipher("key", AlphabetCyrillicLower)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := "при\xffвет \xc3 abc \xed\xa0\x80"
+	enc := c.Encrypt(s)
+	for _, bad := range []string{"\xff", "\xc3 ", "\xed\xa0\x80"} {
+		if !strings.Contains(enc, bad) {
+			t.Errorf("Encrypt(%q) = %q, invalid bytes %q not passed through", s, enc, bad)
+		}
+	}
+	if dec := c.Decrypt(enc); dec != s {
+		t.Errorf("Decrypt(%q) = %q, want %q", enc, dec, s)
+	}
+}
+
+func TestRuneSubstitutionOverlap(t *testing.T) {
+	// Vietnamese lowercase covers the ASCII lowercase letters
+	if _, err := NewRuneSubstitutionCipher("key", AlphabetLower, AlphabetVietnameseLower); err == nil {
+		t.Error("overlapping alphabets accepted")
+	}
+	if _, err := NewRuneSubstitutionCipher("key", AlphabetGreekLower, nil); err == nil {
+		t.Error("nil alphabet accepted")
+	}
+}
+
+func TestParseRuneRanges(t *testing.T) {
+	got, err := parseRuneRanges("U+0400-04FF,0370-03FF")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Radix() != 0x100 || got[1].Radix() != 0x90 {
+		t.Fatalf("parseRuneRanges = %v", got)
+	}
+	if got[0].Rune(0) != 0x0400 || got[1].Rune(0x8F) != 0x03FF {
+		t.Errorf("ranges start at %U and end at %U", got[0].Rune(0), got[1].Rune(0x8F))
+	}
+
+	for _, bad := range []string{
+		"04FF-0400", // reversed
+		"0400-zz",   // not hex
+		"",          // empty
+		"FFF0-FFFF", // contains U+FFFD
+	} {
+		if _, err := parseRuneRanges(bad); err == nil {
+			t.Errorf("parseRuneRanges(%q) succeeded", bad)
+		}
+	}
+
+	// surrogates are skipped rather than rejected
+	a, err := AlphabetRange(0xD7F0, 0xE00F)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 0xE00F - 0xD7F0 + 1 - 0x800; a.Radix() != want {
+		t.Errorf("AlphabetRange over the surrogates has radix %d, want %d", a.Radix(), want)
+	}
+}
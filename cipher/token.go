@@ -0,0 +1,251 @@
+package cipher
+
+import (
+	"errors"
+	"strings"
+)
+
+// ---------------------------
+// base64 / base32 / base58 tokens
+// ---------------------------
+
+// TokenEncoding is the text encoding of an opaque token. Encrypted tokens
+// use the same alphabet and length and keep the padding; base64 and
+// base32 ones also decode to the same number of bytes.
+type TokenEncoding struct {
+	name     string
+	alphabet *Alphabet
+
+	// bits per character for the RFC 4648 encodings, 0 for base58
+	bits int
+	pad  byte
+}
+
+// Built-in token encodings. Padding is optional for the RFC 4648 ones: a
+// padded token keeps its padding and an unpadded one stays unpadded.
+var (
+	// TokenBase64 is standard base64 (RFC 4648 section 4).
+	TokenBase64 = &TokenEncoding{name: "base64", bits: 6, pad: '=',
+		alphabet: MustAlphabet("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/")}
+	// TokenBase64URL is URL- and filename-safe base64 (RFC 4648 section 5).
+	TokenBase64URL = &TokenEncoding{name: "base64url", bits: 6, pad: '=',
+		alphabet: MustAlphabet("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_")}
+	// TokenBase32 is standard base32 (RFC 4648 section 6), uppercase.
+	TokenBase32 = &TokenEncoding{name: "base32", bits: 5, pad: '=',
+		alphabet: MustAlphabet("ABCDEFGHIJKLMNOPQRSTUVWXYZ234567")}
+	// TokenBase32Hex is base32 with the extended hex alphabet (RFC 4648
+	// section 7), uppercase.
+	TokenBase32Hex = &TokenEncoding{name: "base32hex", bits: 5, pad: '=',
+		alphabet: MustAlphabet("0123456789ABCDEFGHIJKLMNOPQRSTUV")}
+	// TokenBase58 is the Bitcoin base58 alphabet. Leading '1's, which
+	// stand for leading zero bytes, are kept and the next character is
+	// never encrypted to a '1'. Checksums inside the token, such as
+	// Base58Check's, are not recomputed.
+	TokenBase58 = &TokenEncoding{name: "base58",
+		alphabet: MustAlphabet("123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz")}
+)
+
+// String returns the encoding's name, e.g. "base64url".
+func (e *TokenEncoding) String() string { return e.name }
+
+// ErrInvalidToken is returned for input that is not a canonical token of
+// its encoding: a character outside the alphabet, a length no byte string
+// encodes to, wrong padding, or non-zero unused bits in the last
+// character.
+var ErrInvalidToken = errors.New("not a valid token")
+
+// TokenCipher encrypts tokens within their encoding.
+type TokenCipher interface {
+	EncryptToken(s string, enc *TokenEncoding, opts TokenOptions) (string, error)
+	DecryptToken(s string, enc *TokenEncoding, opts TokenOptions) (string, error)
+}
+
+// TokenOptions configures EncryptToken.
+type TokenOptions struct {
+	// Tweak is bound next to the encoding for the FPE ciphers; see
+	// MaxTweakLen. The substitution cipher has no tweak and rejects a
+	// non-nil one.
+	Tweak []byte
+}
+
+var (
+	_ TokenCipher = (*SubstitutionCipher)(nil)
+	_ TokenCipher = (*FPECipher)(nil)
+	_ TokenCipher = (*FF31Cipher)(nil)
+)
+
+var tweakToken = "TOKEN"
+
+// tokenPos is the set of alphabet values allowed at one position.
+type tokenPos struct {
+	radix int // values 0..radix-1, each stands for alphabet value v<<shift+base
+	shift int
+	base  int
+}
+
+func (p tokenPos) value(idx int) int { return (idx - p.base) >> p.shift }
+func (p tokenPos) index(v int) int   { return v<<p.shift + p.base }
+
+func (p tokenPos) allows(idx int) bool {
+	return idx >= p.base && (idx-p.base)&(1<<p.shift-1) == 0 && p.value(idx) < p.radix
+}
+
+// parseToken checks s against enc and returns the alphabet values of the
+// encrypted characters, what is allowed at each, and the kept head and
+// tail (leading base58 zeros, padding).
+func parseToken(s string, enc *TokenEncoding) (head string, idx []int, pos []tokenPos, tail string, err error) {
+	if enc == nil {
+		return "", nil, nil, "", errors.New("nil token encoding")
+	}
+	body := s
+	if enc.pad != 0 {
+		body = strings.TrimRight(s, string(enc.pad))
+		tail = s[len(body):]
+	}
+	if enc.bits == 0 {
+		zero := byte(enc.alphabet.Rune(0))
+		trimmed := strings.TrimLeft(body, string(zero))
+		head, body = body[:len(body)-len(trimmed)], trimmed
+	}
+	if len(body) == 0 && head == "" {
+		return "", nil, nil, "", ErrInvalidToken
+	}
+	for i := 0; i < len(body); i++ {
+		v := enc.alphabet.Index(rune(body[i]))
+		if v < 0 {
+			return "", nil, nil, "", ErrInvalidToken
+		}
+		idx = append(idx, v)
+		pos = append(pos, tokenPos{radix: enc.alphabet.Radix()})
+	}
+
+	if enc.bits == 0 {
+		if len(pos) > 0 {
+			pos[0] = tokenPos{radix: enc.alphabet.Radix() - 1, base: 1}
+		}
+		return head, idx, pos, "", nil
+	}
+	// the last character carries unused bits, which must be zero
+	unused := len(body) * enc.bits % 8
+	if unused >= enc.bits {
+		return "", nil, nil, "", ErrInvalidToken
+	}
+	if tail != "" {
+		group := 8 / gcd(8, enc.bits) // characters per padded group
+		if (len(body)+len(tail))%group != 0 || len(tail) >= group {
+			return "", nil, nil, "", ErrInvalidToken
+		}
+	}
+	last := len(pos) - 1
+	pos[last] = tokenPos{radix: 1 << (enc.bits - unused), shift: unused}
+	if !pos[last].allows(idx[last]) {
+		return "", nil, nil, "", ErrInvalidToken
+	}
+	return "", idx, pos, tail, nil
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+func formatToken(head string, idx []int, tail string, enc *TokenEncoding) string {
+	var b strings.Builder
+	b.Grow(len(head) + len(idx) + len(tail))
+	b.WriteString(head)
+	for _, v := range idx {
+		b.WriteRune(enc.alphabet.Rune(v))
+	}
+	b.WriteString(tail)
+	return b.String()
+}
+
+// ---- FPE ----
+
+// EncryptToken encrypts a base64, base32 or base58 token, e.g. a session
+// ID, as one domain: every character is encrypted within the alphabet,
+// except the unused low bits of the last character, which stay zero, and
+// the padding, which stays. The result passes the same format checks as
+// the input. Input must be canonical (ErrInvalidToken otherwise). FF3-1
+// encrypts fewer than 192 bits at once, so it takes tokens of at most 23
+// bytes: 31 base64 characters (plus padding), 37 base32 or 32 base58
+// characters. Longer tokens need FF1.
+func (c *fpeCore) EncryptToken(s string, enc *TokenEncoding, opts TokenOptions) (string, error) {
+	return c.token(s, enc, opts.Tweak, true)
+}
+
+// DecryptToken is the inverse of EncryptToken with the same options.
+func (c *fpeCore) DecryptToken(s string, enc *TokenEncoding, opts TokenOptions) (string, error) {
+	return c.token(s, enc, opts.Tweak, false)
+}
+
+func (c *fpeCore) token(s string, enc *TokenEncoding, tweak []byte, encrypt bool) (string, error) {
+	if len(tweak) > MaxTweakLen {
+		return "", ErrTweakTooLong
+	}
+	head, idx, pos, tail, err := parseToken(s, enc)
+	if err != nil {
+		return "", err
+	}
+	vals := make([]int, len(idx))
+	radices := make([]int, len(idx))
+	for i, p := range pos {
+		vals[i], radices[i] = p.value(idx[i]), p.radix
+	}
+	t := classTweak(DeriveTweak(tweakToken, enc.name), tweak)
+	if vals, err = c.permuteNumerals(vals, radices, t, encrypt); err != nil {
+		return "", err
+	}
+	for i, p := range pos {
+		idx[i] = p.index(vals[i])
+	}
+	return formatToken(head, idx, tail, enc), nil
+}
+
+// ---- substitution ----
+
+// EncryptToken encrypts a token character by character with the
+// substitution tables, keeping the same alphabet, padding and unused bits
+// as the FPE version. A table entry that leaves the alphabet (or the
+// values allowed at that position) is applied again until it is back
+// inside, which is still a bijection on the allowed values.
+func (c *SubstitutionCipher) EncryptToken(s string, enc *TokenEncoding, opts TokenOptions) (string, error) {
+	return c.token(s, enc, opts, &c.enc)
+}
+
+// DecryptToken is the inverse of EncryptToken.
+func (c *SubstitutionCipher) DecryptToken(s string, enc *TokenEncoding, opts TokenOptions) (string, error) {
+	return c.token(s, enc, opts, &c.dec)
+}
+
+func (c *SubstitutionCipher) token(s string, enc *TokenEncoding, opts TokenOptions, table *[256]byte) (string, error) {
+	if opts.Tweak != nil {
+		return "", errors.New("substitution cipher does not take a tweak")
+	}
+	head, idx, pos, tail, err := parseToken(s, enc)
+	if err != nil {
+		return "", err
+	}
+	for i, p := range pos {
+		if idx[i], err = substituteWithin(idx[i], p, enc.alphabet, table); err != nil {
+			return "", err
+		}
+	}
+	return formatToken(head, idx, tail, enc), nil
+}
+
+// substituteWithin applies table to the character with value v until the
+// result is allowed by p. The table is a permutation of the bytes, so the
+// walk comes back to v at the latest.
+func substituteWithin(v int, p tokenPos, a *Alphabet, table *[256]byte) (int, error) {
+	ch := byte(a.Rune(v))
+	for walks := 0; walks <= maxCycleWalks; walks++ {
+		ch = table[ch]
+		if v := a.Index(rune(ch)); v >= 0 && p.allows(v) {
+			return v, nil
+		}
+	}
+	return 0, ErrCycleWalkLimit
+}
@@ -0,0 +1,36 @@
+package cipher
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestFF31TokenLimit(t *testing.T) {
+	cores := fpeCores(t)
+	f3, f1 := cores["FF3-1"], cores["FF1"]
+	b32 := base32.StdEncoding.WithPadding(base32.NoPadding)
+	for _, tc := range []struct {
+		enc       *TokenEncoding
+		fits, not string
+	}{
+		{TokenBase64, base64.StdEncoding.EncodeToString(make([]byte, 23)), base64.StdEncoding.EncodeToString(make([]byte, 24))},
+		{TokenBase64URL, base64.RawURLEncoding.EncodeToString(make([]byte, 23)), base64.RawURLEncoding.EncodeToString(make([]byte, 24))},
+		{TokenBase32, b32.EncodeToString(make([]byte, 23)), b32.EncodeToString(make([]byte, 24))},
+		{TokenBase58, "2" + strings.Repeat("z", 31), "2" + strings.Repeat("z", 32)},
+	} {
+		enc, err := f3.EncryptToken(tc.fits, tc.enc, TokenOptions{})
+		if err != nil {
+			t.Errorf("%s: FF3-1 EncryptToken of %d characters: %v", tc.enc, len(tc.fits), err)
+		} else if dec, err := f3.DecryptToken(enc, tc.enc, TokenOptions{}); err != nil || dec != tc.fits {
+			t.Errorf("%s: DecryptToken(%q) = %q, %v; want %q", tc.enc, enc, dec, err, tc.fits)
+		}
+		if _, err := f3.EncryptToken(tc.not, tc.enc, TokenOptions{}); err == nil {
+			t.Errorf("%s: FF3-1 EncryptToken of %d characters succeeded", tc.enc, len(tc.not))
+		}
+		if _, err := f1.EncryptToken(tc.not, tc.enc, TokenOptions{}); err != nil {
+			t.Errorf("%s: FF1 EncryptToken of %d characters: %v", tc.enc, len(tc.not), err)
+		}
+	}
+}